@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/json"
 	"flag"
@@ -11,22 +12,36 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"cyber-range-config/internal/client/common"
 	"cyber-range-config/internal/client/windows"
 	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/logging"
 )
 
 const (
 	maxStartupDelay = 30 // Maximum random delay in seconds
 )
 
+// responseKey is the base64 Ed25519 public key config responses must be signed with,
+// baked in at build time with -ldflags "-X main.responseKey=..."
+var responseKey string
+
 func main() {
 	// Parse flags
 	serverURL := flag.String("server", "", "Configuration server URL (e.g., http://server:8080)")
-	interfaceName := flag.String("interface", "", "Network interface name (optional)")
+	interfaceName := flag.String("interface", "", "Network interface whose MAC is tried first (optional)")
 	noDelay := flag.Bool("no-delay", false, "Skip random startup delay")
+	logFormat := flag.String("log-format", logging.FormatText, "Log format: text or json")
+	caFile := flag.String("ca", "", "CA certificate (PEM) to verify an HTTPS server")
+	certFile := flag.String("cert", "", "Client certificate (PEM) for mutual TLS; may include the key")
+	keyFile := flag.String("key", "", "Client private key (PEM) if not in -cert")
+	pin := flag.String("pin", "", "SHA-256 of the server's public key to pin (hex)")
+	tokenFile := flag.String("token-file", "", `File with this instance's bootstrap token (default: C:\ProgramData\cyber-range\token if present, else the SMBIOS OEM string from forge)`)
+	verifyKeyFile := flag.String("verify-key", "", "File with the base64 Ed25519 key config responses must be signed with (overrides the built-in key)")
+	insecureNoVerify := flag.Bool("insecure-no-verify", false, "Apply config responses without verifying their signature (testing only)")
 	flag.Parse()
 
 	// Set up logging
@@ -42,8 +57,10 @@ func main() {
 	}
 	defer logFile.Close()
 
-	log.SetOutput(io.MultiWriter(os.Stdout, logFile))
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if err := logging.Setup(io.MultiWriter(os.Stdout, logFile), *logFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
 
 	log.Println("=== Cyber Range Configuration Client (Windows) Starting ===")
 
@@ -58,6 +75,19 @@ func main() {
 		log.Fatal("Server URL is required. Use -server flag.")
 	}
 
+	client, err := common.NewHTTPClient(*caFile, *certFile, *keyFile, *pin)
+	if err != nil {
+		log.Fatalf("Failed to set up HTTPS: %v", err)
+	}
+
+	// Responses must be signed unless verification is explicitly turned off
+	var verifyKey ed25519.PublicKey
+	if *insecureNoVerify {
+		log.Println("Warning: -insecure-no-verify set; config responses are not verified")
+	} else if verifyKey, err = common.LoadResponseKey(*verifyKeyFile, responseKey); err != nil {
+		log.Fatalf("Failed to load response verification key: %v", err)
+	}
+
 	// Random startup delay to stagger requests
 	if !*noDelay {
 		delay := randomDelay(maxStartupDelay)
@@ -65,51 +95,71 @@ func main() {
 		time.Sleep(time.Duration(delay) * time.Second)
 	}
 
-	// Get MAC address
-	var mac string
-	if *interfaceName != "" {
-		mac, err = common.GetMACByName(*interfaceName)
-	} else {
-		mac, err = common.GetPrimaryMAC()
-	}
+	// Send every MAC; the server says which one belongs to this instance
+	macs, err := common.GetMACs(*interfaceName)
 	if err != nil {
-		log.Fatalf("Failed to get MAC address: %v", err)
+		log.Fatalf("Failed to get MAC addresses: %v", err)
 	}
-	log.Printf("Using MAC address: %s", mac)
+	log.Printf("Using MAC addresses: %s", strings.Join(macs, ", "))
+
+	report := common.NewReporter(client, *serverURL, "windows", macs[0])
+	report.SetTokenLoader(func() (string, error) { return windows.LoadToken(*tokenFile) })
 
 	// Request configuration with retries
-	cfg, err := requestConfigWithRetry(*serverURL, mac, 10, 15*time.Second)
+	cfg, err := requestConfigWithRetry(client, *serverURL, macs, *tokenFile, verifyKey, 10, 15*time.Second)
 	if err != nil {
-		log.Fatalf("Failed to get configuration: %v", err)
+		report.Fatalf("Failed to get configuration: %v", err)
+	}
+	report.StepDone("fetch")
+	if cfg.MatchedMAC != "" {
+		report.SetMAC(cfg.MatchedMAC)
 	}
-	log.Printf("Received config: hostname=%s, dhcp=%v", cfg.Hostname, cfg.Network.DHCP)
+	report.SetHostname(cfg.Hostname)
+	report.SetRequestID(cfg.RequestID)
+
+	// Tag the rest of this run with the server's request ID
+	logging.WithRequestID(cfg.RequestID)
+	log.Printf("Received config: hostname=%s, matched_mac=%s, interface=%s, dhcp=%v", cfg.Hostname, cfg.MatchedMAC, cfg.Interface, cfg.Network.DHCP)
 
 	// Apply hostname
-	log.Printf("Setting hostname to: %s", cfg.Hostname)
-	if err := windows.SetHostname(cfg.Hostname); err != nil {
-		log.Fatalf("Failed to set hostname: %v", err)
+	log.Printf("Setting hostname to: %s (fqdn %q)", cfg.Hostname, cfg.FQDN)
+	// A bad hostname must not stop the network from being configured
+	if err := windows.SetHostname(cfg.Hostname, cfg.FQDN); err != nil {
+		log.Printf("Warning: Failed to set hostname: %v", err)
+		report.AddError(fmt.Errorf("hostname: %w", err))
+	} else {
+		log.Println("Hostname set successfully (requires reboot)")
 	}
-	log.Println("Hostname set successfully (requires reboot)")
+	report.StepDone("hostname")
 
 	// Apply network configuration
 	log.Println("Configuring network...")
 	if err := windows.ConfigureNetwork(cfg.Network); err != nil {
-		log.Fatalf("Failed to configure network: %v", err)
+		report.Fatalf("Failed to configure network: %v", err)
 	}
 	log.Println("Network configured successfully")
+	report.AddInterface(cfg.Interface)
+	report.StepDone("network")
+
+	// Scenario scripts in the guest read their role, team or flag seed from here
+	if err := common.WriteMetadata(windows.GetMetadataPath(), cfg.Metadata); err != nil {
+		report.Fatalf("Failed to write metadata: %v", err)
+	}
+	log.Printf("Wrote %d metadata key(s) to %s", len(cfg.Metadata), windows.GetMetadataPath())
 
 	// Create marker file
 	if err := windows.CreateMarker(cfg.Hostname); err != nil {
-		log.Fatalf("Failed to create marker file: %v", err)
+		report.Fatalf("Failed to create marker file: %v", err)
 	}
 	log.Println("Marker file created.")
 
 	log.Println("=== Configuration Complete ===")
+	report.Send()
 	log.Println("Initiating system reboot in 5 seconds...")
 
 	// Reboot with 5 second delay to allow logs to flush
 	if err := windows.Reboot(5); err != nil {
-		log.Fatalf("Failed to initiate reboot: %v", err)
+		report.Fatalf("Failed to initiate reboot: %v", err)
 	}
 }
 
@@ -122,9 +172,10 @@ func randomDelay(max int) int {
 	return int(n.Int64())
 }
 
-// requestConfigWithRetry requests config with retries
-func requestConfigWithRetry(serverURL, mac string, maxRetries int, retryDelay time.Duration) (*config.ConfigResponse, error) {
+// requestConfigWithRetry requests config with retries, reloading the bootstrap token each time
+func requestConfigWithRetry(client *http.Client, serverURL string, macs []string, tokenFile string, verifyKey ed25519.PublicKey, maxRetries int, retryDelay time.Duration) (*config.ConfigResponse, error) {
 	var lastErr error
+	var secret string
 
 	for i := 0; i < maxRetries; i++ {
 		if i > 0 {
@@ -132,7 +183,22 @@ func requestConfigWithRetry(serverURL, mac string, maxRetries int, retryDelay ti
 			time.Sleep(retryDelay)
 		}
 
-		cfg, err := requestConfig(serverURL, mac)
+		// Re-read the bootstrap token every attempt: it may be set after we booted
+		// There is no /dev/lxd/sock on Windows; the token comes from a file or SMBIOS
+		current, err := windows.LoadToken(tokenFile)
+		if err != nil {
+			log.Printf("Warning: Failed to load bootstrap token: %v", err)
+		}
+		switch {
+		case i > 0 && current == secret:
+		case current == "":
+			log.Println("No bootstrap token found; sending unsigned requests")
+		default:
+			log.Println("Loaded bootstrap token; signing requests")
+		}
+		secret = current
+
+		cfg, err := requestConfig(client, serverURL, macs, secret, verifyKey)
 		if err == nil {
 			return cfg, nil
 		}
@@ -144,32 +210,55 @@ func requestConfigWithRetry(serverURL, mac string, maxRetries int, retryDelay ti
 	return nil, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
-// requestConfig requests configuration from the server
-func requestConfig(serverURL, mac string) (*config.ConfigResponse, error) {
+// requestConfig requests configuration from the server and verifies its signature
+func requestConfig(client *http.Client, serverURL string, macs []string, secret string, verifyKey ed25519.PublicKey) (*config.ConfigResponse, error) {
 	u, err := url.Parse(serverURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid server URL: %w", err)
 	}
 	u.Path = "/config"
 	q := u.Query()
-	q.Set("mac", mac)
+	q["mac"] = macs
+	q.Set("client", "windows")
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	common.SignRequest(req, secret, macs)
+	nonce, err := common.SetNonce(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	requestID := resp.Header.Get(logging.RequestIDHeader)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response (request %s): %w", requestID, err)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("server returned %d (request %s): %s", resp.StatusCode, requestID, string(body))
+	}
+
+	// Nothing from the response is used until the server's signature checks out
+	if err := common.VerifyResponse(verifyKey, resp, body, macs, nonce); err != nil {
+		return nil, fmt.Errorf("%w (request %s)", err, requestID)
 	}
 
 	var cfg config.ConfigResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse response (request %s): %w", requestID, err)
 	}
+	cfg.RequestID = requestID
 
 	return &cfg, nil
 }
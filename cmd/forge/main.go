@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"cyber-range-config/internal/forge"
+	"cyber-range-config/internal/logging"
 )
 
 const version = "1.0.0"
@@ -15,6 +18,7 @@ func main() {
 
 	// Parse global options
 	var chdir string
+	logFormat := logging.FormatText
 	var showHelp, showVersion bool
 	var commandArgs []string
 	var command string
@@ -29,6 +33,12 @@ func main() {
 			continue
 		}
 
+		if strings.HasPrefix(arg, "-log-format=") {
+			logFormat = strings.TrimPrefix(arg, "-log-format=")
+			i++
+			continue
+		}
+
 		if arg == "-help" || arg == "--help" || arg == "-h" {
 			showHelp = true
 			i++
@@ -53,6 +63,11 @@ func main() {
 		i++
 	}
 
+	if err := logging.Setup(os.Stdout, logFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Handle global flags
 	if showVersion {
 		fmt.Printf("Forge v%s\n", version)
@@ -67,7 +82,7 @@ func main() {
 	// Get working directory
 	workDir, err := forge.GetWorkingDir(chdir)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
@@ -86,11 +101,13 @@ func main() {
 		exitCode = runDestroy(workDir, commandArgs)
 	case "status":
 		exitCode = runStatus(workDir)
+	case "certs":
+		exitCode = runCerts(workDir, commandArgs)
 	case "version":
 		fmt.Printf("Forge v%s\n", version)
 		exitCode = 0
 	default:
-		printError(fmt.Sprintf("Unknown command: %s", command))
+		slog.Error("Unknown command", "command", command)
 		printHelp()
 		exitCode = 1
 	}
@@ -113,11 +130,14 @@ Main commands:
 
 Other commands:
   status        Show current project's subnet allocation
+  certs         Generate the range CA, certificates and response signing key in pki/
   help          Show this help output
   version       Show the current Forge version
 
 Global options:
   -chdir=DIR    Switch to a different working directory before executing
+  -log-format=FORMAT
+                Log format: text (default) or json
   -help         Show this help output
   -version      Show version
 
@@ -131,8 +151,9 @@ On 'forge apply':
   2. Runs tofu apply
   3. Waits for VMs to initialize
   4. Exports LXD instances to instances.json
-  5. Starts config server
-  6. Starts Windows VMs
+  5. Checks instance configs (server -check)
+  6. Starts config server
+  7. Starts Windows VMs
 
 On 'forge destroy':
   1. Stops config server
@@ -150,18 +171,6 @@ Examples:
 	fmt.Print(help)
 }
 
-func printError(msg string) {
-	fmt.Fprintf(os.Stderr, "\033[31m[ERROR]\033[0m %s\n", msg)
-}
-
-func printInfo(msg string) {
-	fmt.Printf("\033[32m[INFO]\033[0m %s\n", msg)
-}
-
-func printWarn(msg string) {
-	fmt.Printf("\033[33m[WARN]\033[0m %s\n", msg)
-}
-
 // runInit initializes subnets.json and runs tofu init
 func runInit(workDir string, args []string) int {
 	// Check for -help
@@ -170,15 +179,15 @@ func runInit(workDir string, args []string) int {
 	}
 
 	// Initialize subnets file
-	printInfo("Initializing subnets file...")
+	slog.Info("Initializing subnets file...")
 	if err := forge.InitSubnetsFile(); err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
-	printInfo(fmt.Sprintf("Subnets file ready: %s", forge.SubnetsFile))
+	slog.Info("Subnets file ready", "path", forge.SubnetsFile)
 
 	// Run tofu init
-	printInfo("Running tofu init...")
+	slog.Info("Running tofu init...")
 	if err := forge.RunTofuPassthrough(workDir, "init", args); err != nil {
 		return 1
 	}
@@ -200,12 +209,12 @@ func runPlan(workDir string, args []string) int {
 
 	projectName, subnetOctet, err := getProjectAndSubnet(workDir, false)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
-	printInfo(fmt.Sprintf("Project: %s", projectName))
-	printInfo(fmt.Sprintf("Subnet: %s (gateway: %s)", forge.FormatSubnet(subnetOctet), forge.FormatGateway(subnetOctet)))
+	slog.Info("Project", "project", projectName)
+	slog.Info("Subnet", "subnet", forge.FormatSubnet(subnetOctet), "gateway", forge.FormatGateway(subnetOctet))
 	fmt.Println()
 
 	if err := forge.RunTofu(workDir, "plan", args, projectName, subnetOctet); err != nil {
@@ -224,7 +233,7 @@ func runApply(workDir string, args []string) int {
 
 	projectName, subnetOctet, err := getProjectAndSubnet(workDir, true)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
@@ -234,8 +243,8 @@ func runApply(workDir string, args []string) int {
 	fmt.Println("==========================================")
 	fmt.Println()
 
-	printInfo(fmt.Sprintf("Project: %s", projectName))
-	printInfo(fmt.Sprintf("Subnet: %s (gateway: %s)", forge.FormatSubnet(subnetOctet), forge.FormatGateway(subnetOctet)))
+	slog.Info("Project", "project", projectName)
+	slog.Info("Subnet", "subnet", forge.FormatSubnet(subnetOctet), "gateway", forge.FormatGateway(subnetOctet))
 	fmt.Println()
 
 	if err := forge.RunTofu(workDir, "apply", args, projectName, subnetOctet); err != nil {
@@ -244,8 +253,10 @@ func runApply(workDir string, args []string) int {
 
 	// Run post-apply steps (wait, export instances, start server, start windows)
 	config := forge.DefaultDeployConfig()
+	config.TLS = forge.HasServerCertificate(workDir)
+	config.SignResponses = forge.HasResponseKey(workDir)
 	if err := forge.RunPostApply(workDir, projectName, config); err != nil {
-		printWarn(err.Error())
+		slog.Warn(err.Error())
 	}
 
 	forge.PrintDeploymentComplete(config, subnetOctet)
@@ -263,19 +274,19 @@ func runDestroy(workDir string, args []string) int {
 	// Get project name
 	projectName, err := forge.ParseProjectName(workDir)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
 	// Get existing subnet (don't allocate new one)
 	subnetOctet, err := forge.GetProjectSubnet(projectName)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
 	if subnetOctet == 0 {
-		printError(fmt.Sprintf("No subnet allocation found for project '%s'", projectName))
+		slog.Error("No subnet allocation found", "project", projectName)
 		return 1
 	}
 
@@ -285,8 +296,8 @@ func runDestroy(workDir string, args []string) int {
 	fmt.Println("==========================================")
 	fmt.Println()
 
-	printInfo(fmt.Sprintf("Project: %s", projectName))
-	printInfo(fmt.Sprintf("Subnet: %s (will be released after destroy)", forge.FormatSubnet(subnetOctet)))
+	slog.Info("Project", "project", projectName)
+	slog.Info("Subnet will be released after destroy", "subnet", forge.FormatSubnet(subnetOctet))
 	fmt.Println()
 
 	// Stop server before destroy
@@ -298,17 +309,17 @@ func runDestroy(workDir string, args []string) int {
 	}
 
 	// Release subnet after successful destroy
-	printInfo("Releasing subnet allocation...")
+	slog.Info("Releasing subnet allocation...")
 	releasedOctet, err := forge.ReleaseSubnet(projectName)
 	if err != nil {
-		printWarn(fmt.Sprintf("Failed to release subnet: %s", err.Error()))
+		slog.Warn("Failed to release subnet", "error", err)
 	} else {
-		printInfo(fmt.Sprintf("Released subnet %s", forge.FormatSubnet(releasedOctet)))
+		slog.Info("Released subnet", "subnet", forge.FormatSubnet(releasedOctet))
 	}
 
 	fmt.Println()
-	printInfo("Destroy complete!")
-	printInfo(fmt.Sprintf("Subnet 10.0.%d.0/24 has been released and is available for reuse.", releasedOctet))
+	slog.Info("Destroy complete!")
+	slog.Info("Subnet has been released and is available for reuse", "subnet", forge.FormatSubnet(releasedOctet))
 
 	return 0
 }
@@ -318,14 +329,14 @@ func runStatus(workDir string) int {
 	// Get project name
 	projectName, err := forge.ParseProjectName(workDir)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
 	// Get subnet
 	subnetOctet, err := forge.GetProjectSubnet(projectName)
 	if err != nil {
-		printError(err.Error())
+		slog.Error(err.Error())
 		return 1
 	}
 
@@ -361,6 +372,51 @@ func runStatus(workDir string) int {
 	return 0
 }
 
+// runCerts generates the project's CA and certificates for the config server
+func runCerts(workDir string, args []string) int {
+	projectName, err := forge.ParseProjectName(workDir)
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+
+	// Certificate names default to the address the server listens on
+	hosts := args
+	if len(hosts) == 0 {
+		hosts = []string{forge.DefaultDeployConfig().ServerIP}
+	}
+
+	slog.Info("Generating certificates", "project", projectName, "hosts", hosts)
+	pin, err := forge.GenerateRangePKI(workDir, projectName, hosts)
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+
+	responseKey, err := forge.GenerateResponseKey(workDir)
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+
+	pkiDir := filepath.Join(workDir, forge.PKIDir)
+	slog.Info("Certificates written", "dir", pkiDir)
+	fmt.Println()
+	fmt.Printf("CA:          %s\n", filepath.Join(pkiDir, forge.CACertFile))
+	fmt.Printf("Server:      %s\n", filepath.Join(pkiDir, forge.ServerCertFile))
+	fmt.Printf("Client:      %s\n", filepath.Join(pkiDir, forge.ClientCertFile))
+	fmt.Printf("Server pin:  %s\n", pin)
+	fmt.Printf("Signing key: %s (public key %s)\n", filepath.Join(pkiDir, forge.ResponseKeyFile), responseKey)
+	fmt.Println()
+	fmt.Println("'forge apply' will start the server with HTTPS. Clients need either")
+	fmt.Printf("  -ca %s  or  -pin %s\n", forge.CACertFile, pin)
+	fmt.Printf("and, with require_client_cert, -cert %s -key %s\n", forge.ClientCertFile, forge.ClientKeyFile)
+	fmt.Println("Config responses will be signed; build the clients with")
+	fmt.Printf("  -ldflags \"-X main.responseKey=%s\"  or pass -verify-key %s\n", responseKey, forge.ResponsePublicKeyFile)
+
+	return 0
+}
+
 // runPassthrough runs tofu command directly without variable injection
 func runPassthrough(workDir string, command string, args []string) int {
 	if err := forge.RunTofuPassthrough(workDir, command, args); err != nil {
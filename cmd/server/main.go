@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/logging"
 	"cyber-range-config/internal/server"
+	"cyber-range-config/internal/signing"
 
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	defaultIdleTimeout = 15 * time.Minute
+	completionInterval = 10 * time.Second // How often check-ins are compared to the expected set
+	watchInterval      = 2 * time.Second  // Default poll interval for instances file changes
 )
 
 func main() {
@@ -25,9 +33,27 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	instancesFile := flag.String("instances", "", "Path to instances JSON file (overrides config)")
 	listenAddr := flag.String("listen", "", "Listen address (overrides config)")
+	watch := flag.String("watch-interval", "", "Reload when the instances file changes, checking this often (overrides config; 0 to disable)")
 	idleTimeout := flag.Duration("idle-timeout", defaultIdleTimeout, "Shutdown after this duration of inactivity (0 to disable)")
+	deadline := flag.Duration("deadline", 0, "Shutdown once every instance has checked in, or after this duration at the latest (replaces -idle-timeout; 0 to disable)")
+	completeOn := flag.String("complete-on", "report", "What counts as checked in with -deadline: report or fetch")
+	logFormat := flag.String("log-format", "", "Log format: text or json (overrides config)")
+	tlsCert := flag.String("tls-cert", "", "Server certificate for HTTPS (overrides config)")
+	tlsKey := flag.String("tls-key", "", "Server private key for HTTPS (overrides config)")
+	clientCA := flag.String("client-ca", "", "CA for verifying client certificates (overrides config)")
+	requireToken := flag.Bool("require-token", false, "Reject instances without a bootstrap token (or require_token in config)")
+	allowedSources := flag.String("allowed-sources", "", "Comma-separated CIDRs allowed on the client endpoints (overrides config)")
+	adminSources := flag.String("admin-sources", "", "Comma-separated CIDRs allowed on the admin endpoints (overrides config; default loopback)")
+	responseKey := flag.String("response-key", "", "Ed25519 private key (PEM) for signing config responses (overrides config)")
+	adminToken := flag.String("admin-token", "", "Bearer token for the admin endpoints (overrides config; or CR_ADMIN_TOKEN)")
+	strictSource := flag.Bool("strict-source", false, "Require the source address to match the requested instance (or strict_source in config)")
+	check := flag.Bool("check", false, "Validate the instance configs, print any problems and exit (non-zero if there are problems)")
 	flag.Parse()
 
+	if *completeOn != "report" && *completeOn != "fetch" {
+		log.Fatalf("Invalid -complete-on %q: use report or fetch", *completeOn)
+	}
+
 	// Load configuration
 	cfg, err := loadConfig(*configPath)
 	if err != nil {
@@ -38,25 +64,125 @@ func main() {
 		}
 	}
 
-	// Override with command line flags
+	// Override with command line flags (an explicit instances file wins over LXD)
 	if *instancesFile != "" {
 		cfg.InstancesFile = *instancesFile
+		cfg.LXD.URL = ""
 	}
 	if *listenAddr != "" {
 		cfg.Listen = *listenAddr
 	}
+	if *watch != "" {
+		cfg.WatchInterval = *watch
+	}
+	if *logFormat != "" {
+		cfg.LogFormat = *logFormat
+	}
+	if *tlsCert != "" {
+		cfg.TLSCert = *tlsCert
+	}
+	if *tlsKey != "" {
+		cfg.TLSKey = *tlsKey
+	}
+	if *clientCA != "" {
+		cfg.ClientCA = *clientCA
+	}
+	if *requireToken {
+		cfg.RequireToken = true
+	}
+	if *allowedSources != "" {
+		cfg.AllowedSources = strings.Split(*allowedSources, ",")
+	}
+	if *adminSources != "" {
+		cfg.AdminSources = strings.Split(*adminSources, ",")
+	}
+	if *strictSource {
+		cfg.StrictSource = true
+	}
+	if *responseKey != "" {
+		cfg.ResponseKey = *responseKey
+	}
+	if *adminToken != "" {
+		cfg.AdminToken = *adminToken
+	} else if env := os.Getenv("CR_ADMIN_TOKEN"); env != "" {
+		cfg.AdminToken = env
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.LogFormat); err != nil {
+		log.Fatalf("Invalid log format: %v", err)
+	}
 
 	// Validate
-	if cfg.InstancesFile == "" {
-		log.Fatal("No instances file specified")
+	if cfg.InstancesFile == "" && cfg.LXD.URL == "" {
+		log.Fatal("No instances file or LXD URL specified")
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		log.Fatal("tls_cert and tls_key must be set together")
+	}
+	if cfg.ClientCA != "" && cfg.TLSCert == "" {
+		log.Fatal("client_ca requires tls_cert and tls_key")
+	}
+	hostnameRules, err := server.NewHostnameRules(cfg.Hostname)
+	if err != nil {
+		log.Fatalf("Invalid hostname rules: %v", err)
+	}
+	clientPrefixes, err := server.ParsePrefixes(cfg.AllowedSources)
+	if err != nil {
+		log.Fatalf("Invalid allowed_sources: %v", err)
+	}
+	adminPrefixes, err := server.ParsePrefixes(cfg.AdminSources)
+	if err != nil {
+		log.Fatalf("Invalid admin_sources: %v", err)
+	}
+
+	// Create instance source
+	source, err := newInstanceSource(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create instance source: %v", err)
 	}
 
 	// Create server
-	srv, err := server.NewServer(cfg.InstancesFile)
+	srv, err := server.NewServer(source)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
+	// Before -check, so it validates the hostnames clients would get
+	if cfg.Hostname != (config.HostnameConfig{}) {
+		log.Printf("Deriving hostnames: %s", hostnameSummary(cfg.Hostname))
+		srv.SetHostnameRules(hostnameRules)
+	}
+
+	if *check {
+		os.Exit(runCheck(srv, source))
+	}
+
+	if cfg.RequireToken {
+		log.Printf("Bootstrap tokens required for every instance")
+		srv.SetRequireToken(true)
+	}
+	if len(clientPrefixes) > 0 {
+		log.Printf("Client endpoints allowed from: %s", strings.Join(cfg.AllowedSources, ", "))
+	}
+	srv.SetAllowedSources(clientPrefixes)
+	srv.SetAdminSources(adminPrefixes)
+	if cfg.AdminToken != "" {
+		log.Printf("Admin endpoints require a bearer token")
+		srv.SetAdminToken(cfg.AdminToken)
+	}
+	if cfg.StrictSource {
+		log.Printf("Strict source checks enabled: requests must come from the instance's address or MAC")
+		srv.SetStrictSource(true)
+	}
+	if cfg.ResponseKey != "" {
+		key, err := signing.LoadPrivateKey(cfg.ResponseKey)
+		if err != nil {
+			log.Fatalf("Failed to load response signing key: %v", err)
+		}
+		log.Printf("Signing config responses (public key %s)", signing.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+		srv.SetResponseKey(key)
+	}
+
 	// Set up routes
 	mux := http.NewServeMux()
 	srv.RegisterRoutes(mux)
@@ -67,11 +193,65 @@ func main() {
 		Handler: mux,
 	}
 
+	if cfg.TLSCert != "" {
+		tlsConfig, err := server.NewTLSConfig(cfg.ClientCA)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		httpServer.TLSConfig = tlsConfig
+	}
+
 	// Channel to signal shutdown
 	shutdown := make(chan struct{})
 
-	// Start idle timeout monitor
-	if *idleTimeout > 0 {
+	// Keep LXD data fresh: periodic refresh, or reload when a MAC is unknown
+	if cfg.LXD.URL != "" {
+		refresh, err := parseRefreshInterval(cfg.LXD.RefreshInterval)
+		if err != nil {
+			log.Fatalf("Invalid lxd.refresh_interval: %v", err)
+		}
+		if refresh > 0 {
+			log.Printf("Refreshing instances from LXD every %v", refresh)
+			srv.StartRefresh(refresh, shutdown)
+		} else {
+			log.Printf("Refreshing instances from LXD on demand")
+			srv.SetReloadOnMiss(true)
+		}
+	} else {
+		interval := watchInterval
+		if cfg.WatchInterval != "" {
+			interval, err = parseRefreshInterval(cfg.WatchInterval)
+			if err != nil {
+				log.Fatalf("Invalid watch_interval: %v", err)
+			}
+		}
+		if interval > 0 {
+			log.Printf("Watching %s for changes every %v", cfg.InstancesFile, interval)
+			srv.StartWatch(cfg.InstancesFile, interval, shutdown)
+		}
+	}
+
+	// Reload on SIGHUP, e.g. after re-running lxc list
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				log.Printf("Received SIGHUP, reloading instances")
+				if err := srv.Reload(); err != nil {
+					log.Printf("Error reloading instances: %v", err)
+				}
+			case <-shutdown:
+				return
+			}
+		}
+	}()
+
+	// Start completion monitor, or the idle timeout monitor
+	if *deadline > 0 {
+		go watchCompletion(srv, *deadline, *completeOn == "report", shutdown)
+	} else if *idleTimeout > 0 {
 		go func() {
 			ticker := time.NewTicker(30 * time.Second)
 			defer ticker.Stop()
@@ -116,20 +296,34 @@ func main() {
 	// Start server in goroutine
 	go func() {
 		log.Printf("Starting server on %s", cfg.Listen)
-		log.Printf("Instances file: %s", cfg.InstancesFile)
-		log.Printf("Endpoints: GET /config?mac=XX:XX:XX:XX:XX:XX, POST /reload, GET /status")
+		log.Printf("Instance source: %s", source)
+		log.Printf("Endpoints: GET /config?mac=XX:XX:XX:XX:XX:XX[&mac=...], POST /reload, GET /status, POST /report, GET /metrics, GET /instances[/{name}[/raw]]")
 
-		if *idleTimeout > 0 {
+		if *deadline > 0 {
+			log.Printf("Will shutdown when every instance has checked in (%s), or after %v", *completeOn, *deadline)
+		} else if *idleTimeout > 0 {
 			log.Printf("Will shutdown after %v of inactivity", *idleTimeout)
 		}
 
-		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSCert != "" {
+			if cfg.ClientCA != "" {
+				log.Printf("TLS enabled, client certificates required (CA %s)", cfg.ClientCA)
+			} else {
+				log.Printf("TLS enabled")
+			}
+			err = httpServer.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
 
 	// Wait for shutdown signal
 	<-shutdown
+	logProgress(srv)
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -143,6 +337,99 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// watchCompletion shuts down once every expected instance has checked in or the deadline passes
+func watchCompletion(srv *server.Server, deadline time.Duration, requireReport bool, shutdown chan struct{}) {
+	ticker := time.NewTicker(completionInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+
+	log.Printf("Completion shutdown enabled: deadline %v", deadline)
+
+	var last server.Progress
+	for {
+		select {
+		case <-ticker.C:
+			progress := srv.Progress()
+			if progress.Done(requireReport) {
+				log.Printf("All %d instances checked in, initiating shutdown...", progress.Expected)
+				close(shutdown)
+				return
+			}
+
+			if progress.Fetched != last.Fetched || progress.Confirmed != last.Confirmed || progress.Expected != last.Expected {
+				log.Printf("Check-ins: %d/%d fetched config, %d/%d reported", progress.Fetched, progress.Expected, progress.Confirmed, progress.Expected)
+				last = progress
+			}
+
+		case <-timer.C:
+			log.Printf("Deadline of %v reached, initiating shutdown...", deadline)
+			close(shutdown)
+			return
+
+		case <-shutdown:
+			return
+		}
+	}
+}
+
+// logProgress logs which instances never showed up, never reported, or reported errors
+func logProgress(srv *server.Server) {
+	progress := srv.Progress()
+	log.Printf("Check-ins: %d/%d fetched config, %d/%d reported", progress.Fetched, progress.Expected, progress.Confirmed, progress.Expected)
+
+	if len(progress.Missing) > 0 {
+		log.Printf("Never requested config: %s", strings.Join(progress.Missing, ", "))
+	}
+	if len(progress.Unconfirmed) > 0 {
+		log.Printf("Fetched config but never reported: %s", strings.Join(progress.Unconfirmed, ", "))
+	}
+
+	var failed []string
+	for name, report := range srv.Reports() {
+		if !report.Success {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		log.Printf("Reported errors: %s", strings.Join(failed, ", "))
+	}
+}
+
+// runCheck prints the validation problems in the loaded instances and returns the exit code
+func runCheck(srv *server.Server, source server.InstanceSource) int {
+	issues := srv.Issues()
+	if len(issues) == 0 {
+		fmt.Printf("%s: no problems found\n", source)
+		return 0
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("%s: %d problem(s) found\n", source, len(issues))
+	return 1
+}
+
+// hostnameSummary describes the configured hostname rules for the startup log
+func hostnameSummary(cfg config.HostnameConfig) string {
+	var rules []string
+	if cfg.StripPrefix != "" {
+		rules = append(rules, fmt.Sprintf("strip prefix %q", cfg.StripPrefix))
+	}
+	if cfg.StripRegex != "" {
+		rules = append(rules, fmt.Sprintf("strip /%s/", cfg.StripRegex))
+	}
+	if cfg.Case != "" {
+		rules = append(rules, cfg.Case+"case")
+	}
+	if cfg.Domain != "" {
+		rules = append(rules, "domain "+cfg.Domain)
+	}
+	return strings.Join(rules, ", ")
+}
+
 func loadConfig(path string) (*config.ServerConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -164,3 +451,25 @@ func loadConfig(path string) (*config.ServerConfig, error) {
 
 	return &cfg, nil
 }
+
+// newInstanceSource selects the LXD API when configured, otherwise the instances file
+func newInstanceSource(cfg *config.ServerConfig) (server.InstanceSource, error) {
+	if cfg.LXD.URL == "" {
+		return server.NewFileSource(cfg.InstancesFile), nil
+	}
+
+	client, err := server.NewLXDClient(cfg.LXD.Cert, cfg.LXD.Key, cfg.LXD.CA)
+	if err != nil {
+		return nil, err
+	}
+
+	return server.NewLXDSource(cfg.LXD.URL, cfg.LXD.Project, client), nil
+}
+
+// parseRefreshInterval parses a duration string, treating empty as disabled
+func parseRefreshInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -6,37 +6,57 @@ import (
 	"strings"
 )
 
-// GetPrimaryMAC returns the MAC address of the primary network adapter
-func GetPrimaryMAC() (string, error) {
+// GetMACs returns the MAC of every non-loopback interface, with the preferred
+// interface (if any) first and interfaces that are up before those that are down
+// net.Interfaces order is not a reliable way to pick the NIC the server knows about,
+// so clients send all of them and let the server say which one matched
+func GetMACs(preferred string) ([]string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		return "", fmt.Errorf("failed to get network interfaces: %w", err)
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	var macs, down []string
+	seen := make(map[string]bool)
+	if preferred != "" {
+		mac, err := GetMACByName(preferred)
+		if err != nil {
+			return nil, err
+		}
+		macs = append(macs, mac)
+		seen[mac] = true
 	}
 
 	for _, iface := range interfaces {
-		// Skip loopback
-		if iface.Flags&net.FlagLoopback != 0 {
+		// Skip loopback and the preferred interface, already added
+		if iface.Flags&net.FlagLoopback != 0 || iface.Name == preferred {
 			continue
 		}
 
-		// Skip interfaces without MAC
-		if len(iface.HardwareAddr) == 0 {
+		// Skip interfaces without an Ethernet MAC (tunnels, WireGuard, ...)
+		if len(iface.HardwareAddr) != 6 {
 			continue
 		}
 
-		// Skip interfaces that are down
-		if iface.Flags&net.FlagUp == 0 {
+		// VLANs, bridges and bond members often share their parent's MAC
+		mac := strings.ToLower(iface.HardwareAddr.String())
+		if seen[mac] {
 			continue
 		}
+		seen[mac] = true
 
-		// Return first valid MAC
-		mac := iface.HardwareAddr.String()
-		if mac != "" {
-			return strings.ToLower(mac), nil
+		if iface.Flags&net.FlagUp == 0 {
+			down = append(down, mac)
+			continue
 		}
+		macs = append(macs, mac)
 	}
+	macs = append(macs, down...)
 
-	return "", fmt.Errorf("no valid network interface found")
+	if len(macs) == 0 {
+		return nil, fmt.Errorf("no valid network interface found")
+	}
+	return macs, nil
 }
 
 // GetMACByName returns the MAC address of a specific interface
@@ -52,3 +72,39 @@ func GetMACByName(name string) (string, error) {
 
 	return strings.ToLower(iface.HardwareAddr.String()), nil
 }
+
+// GetInterfaceByMAC returns the name of the local interface with the given MAC address
+func GetInterfaceByMAC(mac string) (string, error) {
+	want, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address %s: %w", mac, err)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if strings.EqualFold(iface.HardwareAddr.String(), want.String()) {
+			return iface.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no interface with MAC %s", mac)
+}
+
+// ResolveInterfaceName maps a server-side interface name to the local interface name
+// If the MAC is known locally its interface wins; otherwise the given name is used as-is
+func ResolveInterfaceName(name, mac string) string {
+	if mac == "" {
+		return name
+	}
+	if local, err := GetInterfaceByMAC(mac); err == nil {
+		return local
+	}
+	return name
+}
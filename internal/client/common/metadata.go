@@ -0,0 +1,38 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteMetadata writes the instance metadata from the config response to file as JSON
+// The file is replaced atomically and always written, so keys removed in LXD disappear
+// It is mode 0600 since metadata may carry secrets such as flag seeds (Windows uses the folder ACL)
+func WriteMetadata(file string, metadata map[string]string) error {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace metadata file: %w", err)
+	}
+
+	return nil
+}
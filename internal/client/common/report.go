@@ -0,0 +1,139 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cyber-range-config/internal/config"
+)
+
+// Reporter collects the outcome of a client run and posts it to the server
+type Reporter struct {
+	client    *http.Client
+	serverURL string
+	loadToken func() (string, error)
+	report    config.ClientReport
+	stepStart time.Time
+}
+
+// NewReporter starts a report for the given client type and MAC, sent with the given HTTP client
+func NewReporter(client *http.Client, serverURL, clientType, mac string) *Reporter {
+	now := time.Now()
+	return &Reporter{
+		client:    client,
+		serverURL: serverURL,
+		report: config.ClientReport{
+			Client:    clientType,
+			MAC:       mac,
+			Timings:   make(map[string]float64),
+			StartedAt: now,
+		},
+		stepStart: now,
+	}
+}
+
+// SetMAC replaces the MAC the report is keyed on, e.g. with the one the server matched
+func (r *Reporter) SetMAC(mac string) {
+	r.report.MAC = mac
+}
+
+// SetTokenLoader sets how the bootstrap token used to sign the report is read
+func (r *Reporter) SetTokenLoader(load func() (string, error)) {
+	r.loadToken = load
+}
+
+// SetHostname records the hostname received from the server
+func (r *Reporter) SetHostname(hostname string) {
+	r.report.Hostname = hostname
+}
+
+// SetRequestID records the server's ID for the /config call being applied
+func (r *Reporter) SetRequestID(id string) {
+	r.report.RequestID = id
+}
+
+// AddInterface records an interface the client applied config to
+func (r *Reporter) AddInterface(name string) {
+	r.report.Interfaces = append(r.report.Interfaces, name)
+}
+
+// AddError records a non-fatal error
+func (r *Reporter) AddError(err error) {
+	r.report.Errors = append(r.report.Errors, err.Error())
+}
+
+// StepDone records the time spent since the previous step under the given name
+func (r *Reporter) StepDone(step string) {
+	now := time.Now()
+	r.report.Timings[step] = now.Sub(r.stepStart).Seconds()
+	r.stepStart = now
+}
+
+// Send posts the report to /report; failures are logged and do not stop the client
+func (r *Reporter) Send() {
+	r.report.Success = len(r.report.Errors) == 0
+	r.report.Timings["total"] = time.Since(r.report.StartedAt).Seconds()
+
+	// Load the token at send time so one set during the run is still used
+	var secret string
+	if r.loadToken != nil {
+		var err error
+		if secret, err = r.loadToken(); err != nil {
+			log.Printf("Warning: Failed to load bootstrap token for report: %v", err)
+		}
+	}
+
+	if err := postReport(r.client, r.serverURL, secret, r.report); err != nil {
+		log.Printf("Warning: Failed to send report: %v", err)
+		return
+	}
+	log.Println("Report sent to server")
+}
+
+// Fatalf records the error, sends the report and exits like log.Fatalf
+func (r *Reporter) Fatalf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	r.report.Errors = append(r.report.Errors, msg)
+	r.Send()
+	log.Fatal(msg)
+}
+
+// postReport sends a report to the server's /report endpoint, signed when secret is set
+func postReport(client *http.Client, serverURL, secret string, report config.ClientReport) error {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return fmt.Errorf("invalid server URL: %w", err)
+	}
+	u.Path = "/report"
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signReport(req, secret, report.MAC, body)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
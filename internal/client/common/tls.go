@@ -0,0 +1,81 @@
+package common
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// NewHTTPClient builds the client used to talk to the config server
+// caFile verifies the server certificate, certFile/keyFile authenticate the client (mTLS)
+// and pin is the SHA-256 of the server's public key; all are optional.
+// If keyFile is empty the key is read from certFile (combined PEM).
+func NewHTTPClient(caFile, certFile, keyFile, pin string) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" {
+		if keyFile == "" {
+			keyFile = certFile
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if pin != "" {
+		want := normalizePin(pin)
+		if len(want) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid pin %q: want a hex SHA-256", pin)
+		}
+		// A pin alone authenticates the server, so chain verification is optional
+		if caFile == "" {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("server sent no certificate")
+			}
+			if got := PublicKeyPin(cs.PeerCertificates[0]); got != want {
+				return fmt.Errorf("server certificate pin mismatch: got %s", got)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// PublicKeyPin returns the hex SHA-256 of a certificate's SubjectPublicKeyInfo
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizePin accepts "sha256:" prefixes, colons and upper case
+func normalizePin(pin string) string {
+	pin = strings.TrimPrefix(strings.ToLower(pin), "sha256:")
+	return strings.ReplaceAll(pin, ":", "")
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cyber-range-config/internal/token"
+)
+
+// devLXDSocket is the guest API socket LXD exposes in containers and VMs with lxd-agent
+const devLXDSocket = "/dev/lxd/sock"
+
+// LoadToken reads the instance's bootstrap secret from file, or from the LXD
+// guest API when file is empty. Returns "" if no secret is available.
+func LoadToken(file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if _, err := os.Stat(devLXDSocket); err != nil {
+		return "", nil
+	}
+	return readDevLXDConfig(token.ConfigKey)
+}
+
+// readDevLXDConfig reads a user.* config key of this instance through /dev/lxd/sock
+func readDevLXDConfig(key string) (string, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", devLXDSocket)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://lxd/1.0/config/" + key)
+	if err != nil {
+		return "", fmt.Errorf("devlxd request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read devlxd response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("devlxd returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// SignRequest adds the bootstrap token signature for macs to a /config request
+func SignRequest(req *http.Request, secret string, macs []string) {
+	if secret == "" {
+		return
+	}
+	ts := time.Now().Unix()
+	req.Header.Set(token.TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(token.SignatureHeader, token.Sign(secret, macs, ts))
+}
+
+// signReport adds the bootstrap token signature for mac and body to a /report request
+func signReport(req *http.Request, secret, mac string, body []byte) {
+	if secret == "" {
+		return
+	}
+	ts := time.Now().Unix()
+	req.Header.Set(token.TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(token.SignatureHeader, token.SignBody(secret, []string{mac}, ts, body))
+}
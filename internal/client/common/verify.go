@@ -0,0 +1,54 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cyber-range-config/internal/signing"
+)
+
+// LoadResponseKey returns the public key that config responses must be signed with:
+// the key in file if given, otherwise the one built into the client.
+// Having neither is an error; clients only skip verification when told to explicitly.
+func LoadResponseKey(file, builtin string) (ed25519.PublicKey, error) {
+	value := builtin
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verify key: %w", err)
+		}
+		value = string(data)
+	}
+
+	if value == "" {
+		return nil, fmt.Errorf("no key built in and no -verify-key given (use -insecure-no-verify to apply unsigned responses)")
+	}
+	return signing.ParsePublicKey(value)
+}
+
+// SetNonce adds a fresh random nonce to a /config request and returns it
+func SetNonce(req *http.Request) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(buf)
+	req.Header.Set(signing.NonceHeader, nonce)
+	return nonce, nil
+}
+
+// VerifyResponse checks the server's signature over a /config response body
+// A nil key (only with -insecure-no-verify) skips the check
+func VerifyResponse(key ed25519.PublicKey, resp *http.Response, body []byte, macs []string, nonce string) error {
+	if key == nil {
+		return nil
+	}
+	if err := signing.Verify(key, macs, nonce, body, resp.Header.Get(signing.SignatureHeader)); err != nil {
+		return fmt.Errorf("refusing config response: %w", err)
+	}
+	return nil
+}
@@ -2,17 +2,63 @@ package linux
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"strings"
+
+	"cyber-range-config/internal/config"
 )
 
+// hostsFile maps the FQDN to the local host so hostname -f resolves it
+const hostsFile = "/etc/hosts"
+
 // SetHostname sets the Linux hostname using hostnamectl
 // This works on all systemd-based distros (Ubuntu 16.04+, RHEL 7+, Debian 8+, Fedora)
-func SetHostname(hostname string) error {
+// A non-empty fqdn is also mapped to 127.0.1.1 in /etc/hosts
+func SetHostname(hostname, fqdn string) error {
+	if err := config.ValidateHostname(hostname, config.MaxHostnameLength); err != nil {
+		return err
+	}
+
 	// Use hostnamectl to set the hostname persistently
 	cmd := exec.Command("hostnamectl", "set-hostname", hostname)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("hostnamectl failed: %s - %w", string(output), err)
 	}
 
+	if fqdn == "" {
+		return nil
+	}
+	if err := config.ValidateFQDN(fqdn); err != nil {
+		return err
+	}
+	return setHostsEntry(fqdn, hostname)
+}
+
+// setHostsEntry replaces the 127.0.1.1 line of /etc/hosts (Debian's convention
+// for the local hostname) with the FQDN and short name, or appends one
+func setHostsEntry(fqdn, hostname string) error {
+	data, err := os.ReadFile(hostsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", hostsFile, err)
+	}
+
+	entry := fmt.Sprintf("127.0.1.1\t%s %s", fqdn, hostname)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	replaced := false
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "127.0.1.1" {
+			lines[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+
+	if err := os.WriteFile(hostsFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", hostsFile, err)
+	}
 	return nil
 }
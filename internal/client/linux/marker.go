@@ -14,6 +14,8 @@ const (
 	MarkerFile = ".configured"
 	// LogFile is the log file name
 	LogFile = "config.log"
+	// MetadataFile holds the instance metadata from the server as JSON
+	MetadataFile = "metadata.json"
 )
 
 // EnsureMarkerDir creates the marker directory if needed
@@ -48,3 +50,8 @@ func CreateMarker(hostname string) error {
 func GetLogPath() string {
 	return filepath.Join(MarkerDir, LogFile)
 }
+
+// GetMetadataPath returns the path to the metadata file
+func GetMetadataPath() string {
+	return filepath.Join(MarkerDir, MetadataFile)
+}
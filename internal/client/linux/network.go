@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"cyber-range-config/internal/client/common"
 	"cyber-range-config/internal/config"
 )
 
@@ -25,21 +28,26 @@ const (
 func ConfigureNetwork(cfg config.NetworkConfig) error {
 	method := detectNetworkMethod()
 
+	// Use the NIC that owns the configured MAC when we can find it
+	ifaceName := common.ResolveInterfaceName("", cfg.MAC)
+
 	switch method {
 	case NetworkMethodNetworkManager:
-		return configureNetworkManager("", cfg) // Empty string = auto-detect
+		return configureNetworkManager(ifaceName, cfg) // Empty string = auto-detect
 	case NetworkMethodNetplan:
-		return configureNetplanSingle(cfg)
+		return configureNetplanSingle(ifaceName, cfg)
 	case NetworkMethodIfupdown:
-		return configureIfupdownSingle(cfg)
+		return configureIfupdownSingle(ifaceName, cfg)
 	default:
 		return fmt.Errorf("no supported network configuration method found")
 	}
 }
 
 // ConfigureAllNetworks applies network configuration for multiple interfaces
+// Interfaces are matched to local NICs by MAC address when one is provided
 func ConfigureAllNetworks(networks map[string]config.NetworkConfig) error {
 	method := detectNetworkMethod()
+	networks = resolveLocalNames(networks)
 
 	switch method {
 	case NetworkMethodNetworkManager:
@@ -53,6 +61,41 @@ func ConfigureAllNetworks(networks map[string]config.NetworkConfig) error {
 	}
 }
 
+// resolveLocalNames re-keys networks by local interface name using each entry's MAC
+// Bond/bridge members and VLAN links are renamed to match
+func resolveLocalNames(networks map[string]config.NetworkConfig) map[string]config.NetworkConfig {
+	names := make(map[string]string, len(networks))
+	for name, cfg := range networks {
+		names[name] = name
+		if !cfg.IsVirtual() {
+			names[name] = common.ResolveInterfaceName(name, cfg.MAC)
+		}
+	}
+
+	localName := func(name string) string {
+		if local, ok := names[name]; ok {
+			return local
+		}
+		return name
+	}
+
+	resolved := make(map[string]config.NetworkConfig, len(networks))
+	for name, cfg := range networks {
+		if len(cfg.Members) > 0 {
+			members := make([]string, len(cfg.Members))
+			for i, member := range cfg.Members {
+				members[i] = localName(member)
+			}
+			cfg.Members = members
+		}
+		if cfg.Link != "" {
+			cfg.Link = localName(cfg.Link)
+		}
+		resolved[localName(name)] = cfg
+	}
+	return resolved
+}
+
 // detectNetworkMethod determines which network configuration system is in use
 func detectNetworkMethod() NetworkMethod {
 	// Check for NetworkManager first (most common on modern distros)
@@ -97,57 +140,85 @@ func configureNetworkManager(ifaceName string, cfg config.NetworkConfig) error {
 		}
 	}
 
+	args := []string{"connection", "modify", connName}
+
+	addresses, err := normalizeCIDRs(cfg.IPv4Addresses())
+	if err != nil {
+		return err
+	}
+
 	if cfg.DHCP {
-		// Set to DHCP
-		cmd := exec.Command("nmcli", "connection", "modify", connName,
+		// Set to DHCP (any static addresses are added alongside the lease)
+		// Configured DNS servers are used alongside the lease's; empty clears stale ones
+		args = append(args,
 			"ipv4.method", "auto",
-			"ipv4.addresses", "",
+			"ipv4.addresses", strings.Join(addresses, ","),
 			"ipv4.gateway", "",
-			"ipv4.dns", "")
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("nmcli modify failed: %s - %w", string(output), err)
-		}
+			"ipv4.dns", strings.Join(cfg.IPv4DNS(), ","))
+	} else if len(addresses) == 0 {
+		// No IPv4 on this interface
+		args = append(args, "ipv4.method", "disabled")
 	} else {
-		// Parse CIDR address
-		ip, ipNet, err := net.ParseCIDR(cfg.Address)
-		if err != nil {
-			return fmt.Errorf("invalid address format: %w", err)
-		}
-
-		// Get prefix length
-		ones, _ := ipNet.Mask.Size()
-		address := fmt.Sprintf("%s/%d", ip.String(), ones)
-
-		// Build nmcli command for static IP
-		args := []string{"connection", "modify", connName,
+		// Static IP, multiple addresses are comma separated
+		args = append(args,
 			"ipv4.method", "manual",
-			"ipv4.addresses", address,
-		}
+			"ipv4.addresses", strings.Join(addresses, ","))
 
 		if cfg.Gateway != "" {
 			args = append(args, "ipv4.gateway", cfg.Gateway)
 		}
 
-		if len(cfg.DNS) > 0 {
-			args = append(args, "ipv4.dns", strings.Join(cfg.DNS, ","))
+		if dns := cfg.IPv4DNS(); len(dns) > 0 {
+			args = append(args, "ipv4.dns", strings.Join(dns, ","))
+		}
+	}
+
+	// IPv6 is only touched when the interface has IPv6 settings
+	if cfg.HasIPv6() {
+		ipv6Args, err := nmIPv6Args(cfg)
+		if err != nil {
+			return err
 		}
+		args = append(args, ipv6Args...)
+	}
 
-		cmd := exec.Command("nmcli", args...)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("nmcli modify failed: %s - %w", string(output), err)
+	if len(cfg.Search) > 0 {
+		args = append(args, "ipv4.dns-search", strings.Join(cfg.Search, ","))
+	}
+
+	if cfg.Metric > 0 {
+		args = append(args, "ipv4.route-metric", strconv.Itoa(cfg.Metric))
+	}
+
+	if cfg.MTU > 0 {
+		args = append(args, "802-3-ethernet.mtu", strconv.Itoa(cfg.MTU))
+	}
+
+	// When the NIC gets renamed, bind the connection by MAC so it survives the rename
+	if cfg.SetName != "" && cfg.MAC != "" {
+		if err := writeLinkFile(cfg.MAC, cfg.SetName); err != nil {
+			return err
 		}
+		args = append(args,
+			"802-3-ethernet.mac-address", cfg.MAC,
+			"connection.interface-name", "")
+	}
+
+	cmd := exec.Command("nmcli", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli modify failed: %s - %w", string(output), err)
 	}
 
 	// Add custom routes (works with both DHCP and static)
-	if len(cfg.Routes) > 0 {
+	if routes := cfg.IPv4Routes(); len(routes) > 0 {
 		// Clear existing routes first
 		cmd := exec.Command("nmcli", "connection", "modify", connName, "ipv4.routes", "")
 		cmd.Run() // Ignore error if no routes exist
 
-		// Build routes string: "dest1 nexthop1, dest2 nexthop2"
+		// Build routes string: "dest1 nexthop1 [metric] [table=N], dest2 nexthop2"
 		var routeStrs []string
-		for _, route := range cfg.Routes {
-			routeStrs = append(routeStrs, fmt.Sprintf("%s %s", route.To, route.Via))
+		for _, route := range routes {
+			routeStrs = append(routeStrs, nmRoute(route))
 		}
 		routesArg := strings.Join(routeStrs, ", ")
 
@@ -157,8 +228,20 @@ func configureNetworkManager(ifaceName string, cfg config.NetworkConfig) error {
 		}
 	}
 
+	if routes := cfg.IPv6Routes(); len(routes) > 0 {
+		var routeStrs []string
+		for _, route := range routes {
+			routeStrs = append(routeStrs, nmRoute(route))
+		}
+
+		cmd = exec.Command("nmcli", "connection", "modify", connName, "ipv6.routes", strings.Join(routeStrs, ", "))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("nmcli ipv6 routes failed: %s - %w", string(output), err)
+		}
+	}
+
 	// Apply the changes by reactivating the connection
-	cmd := exec.Command("nmcli", "connection", "up", connName)
+	cmd = exec.Command("nmcli", "connection", "up", connName)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("nmcli connection up failed: %s - %w", string(output), err)
 	}
@@ -167,8 +250,25 @@ func configureNetworkManager(ifaceName string, cfg config.NetworkConfig) error {
 }
 
 // configureNetworkManagerAll configures all interfaces using NetworkManager
+// Devices are handled in dependency order: members are enslaved to their bond or bridge,
+// virtual devices get their own connection before addressing is applied
 func configureNetworkManagerAll(networks map[string]config.NetworkConfig) error {
-	for ifaceName, cfg := range networks {
+	for _, ifaceName := range config.OrderedNames(networks) {
+		cfg := networks[ifaceName]
+
+		if parent, ok := config.ParentOf(networks, ifaceName); ok {
+			if err := enslaveNMConnection(ifaceName, parent, networks[parent].Kind); err != nil {
+				return fmt.Errorf("failed to add %s to %s: %w", ifaceName, parent, err)
+			}
+			continue
+		}
+
+		if cfg.IsVirtual() {
+			if err := ensureNMVirtualConnection(ifaceName, cfg); err != nil {
+				return fmt.Errorf("failed to create %s %s: %w", cfg.Kind, ifaceName, err)
+			}
+		}
+
 		if err := configureNetworkManager(ifaceName, cfg); err != nil {
 			return fmt.Errorf("failed to configure %s: %w", ifaceName, err)
 		}
@@ -176,40 +276,134 @@ func configureNetworkManagerAll(networks map[string]config.NetworkConfig) error
 	return nil
 }
 
+// nmIPv6Args builds the nmcli ipv6.* settings
+// DHCPv6 and SLAAC both map to "auto" (NM follows the RA's managed flag); static addresses are kept alongside
+func nmIPv6Args(cfg config.NetworkConfig) ([]string, error) {
+	addresses, err := normalizeCIDRs(cfg.IPv6Addresses())
+	if err != nil {
+		return nil, err
+	}
+
+	// A gateway without addresses still needs addressing from the router
+	method := "manual"
+	if cfg.DHCP6 || cfg.AcceptRA || len(addresses) == 0 {
+		method = "auto"
+	}
+
+	args := []string{
+		"ipv6.method", method,
+		"ipv6.addresses", strings.Join(addresses, ","),
+		"ipv6.gateway", cfg.Gateway6,
+		"ipv6.dns", strings.Join(cfg.IPv6DNS(), ","),
+	}
+
+	return args, nil
+}
+
+// nmRoute formats a route in nmcli syntax: "dest nexthop [metric] [table=N]"
+func nmRoute(route config.Route) string {
+	parts := []string{route.To, route.Via}
+	if route.Metric > 0 {
+		parts = append(parts, strconv.Itoa(route.Metric))
+	}
+	if route.Table > 0 {
+		parts = append(parts, fmt.Sprintf("table=%d", route.Table))
+	}
+	return strings.Join(parts, " ")
+}
+
+// normalizeCIDRs validates addresses and rewrites them as ip/prefix
+func normalizeCIDRs(addrs []string) ([]string, error) {
+	var normalized []string
+	for _, addr := range addrs {
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address format: %w", err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		normalized = append(normalized, fmt.Sprintf("%s/%d", ip.String(), ones))
+	}
+	return normalized, nil
+}
+
+// writeLinkFile writes a systemd .link file that renames the NIC with the given MAC on next boot
+func writeLinkFile(mac, name string) error {
+	linkDir := "/etc/systemd/network"
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", linkDir, err)
+	}
+
+	content := fmt.Sprintf("# Generated by Cyber Range Configuration Client\n[Match]\nMACAddress=%s\n\n[Link]\nName=%s\n", mac, name)
+	linkPath := filepath.Join(linkDir, fmt.Sprintf("10-cyber-range-%s.link", name))
+	if err := os.WriteFile(linkPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write link file: %w", err)
+	}
+
+	return nil
+}
+
+// sortedNames returns the map keys in a stable order so generated files don't churn
+func sortedNames(networks map[string]config.NetworkConfig) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // getNMConnectionForInterface finds or creates a NetworkManager connection for an interface
 func getNMConnectionForInterface(ifaceName string) (string, error) {
+	connName, found, err := findNMConnection(ifaceName)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return connName, nil
+	}
+
+	// Still not found - create a new connection
+	connName = nmConnectionName(ifaceName)
+	cmd := exec.Command("nmcli", "connection", "add", "type", "ethernet",
+		"con-name", connName, "ifname", ifaceName)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create connection: %s - %w", string(output), err)
+	}
+
+	return connName, nil
+}
+
+// findNMConnection looks up an existing connection by device, then by connection name
+func findNMConnection(ifaceName string) (string, bool, error) {
 	// First, try to find existing connection for this interface
 	cmd := exec.Command("nmcli", "-t", "-f", "NAME,DEVICE", "connection", "show")
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to list connections: %w", err)
+		return "", false, fmt.Errorf("failed to list connections: %w", err)
 	}
 
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	for _, line := range lines {
 		parts := strings.Split(line, ":")
 		if len(parts) >= 2 && parts[1] == ifaceName {
-			return parts[0], nil
+			return parts[0], true, nil
 		}
 	}
 
 	// No existing connection, try to find by connection name matching interface
 	for _, line := range lines {
 		parts := strings.Split(line, ":")
-		if len(parts) >= 1 && parts[0] == ifaceName {
-			return parts[0], nil
+		if len(parts) >= 1 && (parts[0] == ifaceName || parts[0] == nmConnectionName(ifaceName)) {
+			return parts[0], true, nil
 		}
 	}
 
-	// Still not found - create a new connection
-	connName := fmt.Sprintf("cyber-range-%s", ifaceName)
-	cmd = exec.Command("nmcli", "connection", "add", "type", "ethernet",
-		"con-name", connName, "ifname", ifaceName)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("failed to create connection: %s - %w", string(output), err)
-	}
+	return "", false, nil
+}
 
-	return connName, nil
+// nmConnectionName is the name of connections created by the client
+func nmConnectionName(ifaceName string) string {
+	return fmt.Sprintf("cyber-range-%s", ifaceName)
 }
 
 // getNMConnectionName finds the primary NetworkManager connection name
@@ -242,11 +436,14 @@ func getNMConnectionName() (string, error) {
 }
 
 // configureNetplanSingle configures a single interface using Netplan (backwards compat)
-func configureNetplanSingle(cfg config.NetworkConfig) error {
-	// Find primary interface
-	ifaceName, err := getPrimaryInterface()
-	if err != nil {
-		return fmt.Errorf("failed to find primary interface: %w", err)
+// If ifaceName is empty, the primary interface is used
+func configureNetplanSingle(ifaceName string, cfg config.NetworkConfig) error {
+	if ifaceName == "" {
+		var err error
+		ifaceName, err = getPrimaryInterface()
+		if err != nil {
+			return fmt.Errorf("failed to find primary interface: %w", err)
+		}
 	}
 
 	networks := map[string]config.NetworkConfig{
@@ -260,52 +457,38 @@ func configureNetplanAll(networks map[string]config.NetworkConfig) error {
 	content := `# Generated by Cyber Range Configuration Client
 network:
   version: 2
-  ethernets:
 `
 
-	for ifaceName, cfg := range networks {
-		content += fmt.Sprintf("    %s:\n", ifaceName)
+	// netplan groups devices by kind; anything without a kind is an ethernet
+	sections := []struct{ key, kind string }{
+		{"ethernets", config.KindEthernet},
+		{"bonds", config.KindBond},
+		{"bridges", config.KindBridge},
+		{"vlans", config.KindVLAN},
+	}
 
-		if cfg.DHCP {
-			content += "      dhcp4: true\n"
-			// Add custom routes for DHCP
-			if len(cfg.Routes) > 0 {
-				content += "      routes:\n"
-				for _, route := range cfg.Routes {
-					content += fmt.Sprintf("        - to: %s\n          via: %s\n", route.To, route.Via)
-				}
+	for _, section := range sections {
+		var names []string
+		for _, ifaceName := range sortedNames(networks) {
+			kind := networks[ifaceName].Kind
+			if kind == "" {
+				kind = config.KindEthernet
 			}
-		} else {
-			content += "      dhcp4: false\n"
-
-			// Validate and add address
-			if cfg.Address != "" {
-				_, _, err := net.ParseCIDR(cfg.Address)
-				if err != nil {
-					return fmt.Errorf("invalid address format for %s: %w", ifaceName, err)
-				}
-				content += "      addresses:\n"
-				content += fmt.Sprintf("        - %s\n", cfg.Address)
-			}
-
-			// Add routes section if we have gateway or custom routes
-			if cfg.Gateway != "" || len(cfg.Routes) > 0 {
-				content += "      routes:\n"
-				if cfg.Gateway != "" {
-					content += fmt.Sprintf("        - to: default\n          via: %s\n", cfg.Gateway)
-				}
-				for _, route := range cfg.Routes {
-					content += fmt.Sprintf("        - to: %s\n          via: %s\n", route.To, route.Via)
-				}
+			if kind == section.kind {
+				names = append(names, ifaceName)
 			}
+		}
+		if len(names) == 0 {
+			continue
+		}
 
-			// Add DNS servers
-			if len(cfg.DNS) > 0 {
-				content += "      nameservers:\n        addresses:\n"
-				for _, dns := range cfg.DNS {
-					content += fmt.Sprintf("          - %s\n", dns)
-				}
+		content += fmt.Sprintf("  %s:\n", section.key)
+		for _, ifaceName := range names {
+			entry, err := netplanInterface(ifaceName, networks[ifaceName])
+			if err != nil {
+				return err
 			}
+			content += fmt.Sprintf("    %s:\n", ifaceName) + entry
 		}
 	}
 
@@ -324,12 +507,96 @@ network:
 	return nil
 }
 
+// netplanInterface renders the keys of one netplan device entry
+func netplanInterface(ifaceName string, cfg config.NetworkConfig) (string, error) {
+	var content string
+
+	// Rename the NIC by MAC if requested
+	if cfg.SetName != "" && cfg.MAC != "" {
+		content += fmt.Sprintf("      match:\n        macaddress: %s\n      set-name: %s\n", cfg.MAC, cfg.SetName)
+	}
+
+	// Members, parameters and VLAN id/link for virtual devices
+	content += netplanVirtualKeys(cfg)
+
+	content += fmt.Sprintf("      dhcp4: %v\n", cfg.DHCP)
+	if cfg.DHCP6 {
+		content += "      dhcp6: true\n"
+	}
+	if cfg.AcceptRA {
+		content += "      accept-ra: true\n"
+	}
+
+	// Validate and add addresses
+	if addrs := cfg.AllAddresses(); len(addrs) > 0 {
+		content += "      addresses:\n"
+		for _, addr := range addrs {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				return "", fmt.Errorf("invalid address format for %s: %w", ifaceName, err)
+			}
+			content += fmt.Sprintf("        - %s\n", addr)
+		}
+	}
+
+	if cfg.MTU > 0 {
+		content += fmt.Sprintf("      mtu: %d\n", cfg.MTU)
+	}
+
+	// Add routes section if we have gateways or custom routes
+	if cfg.Gateway != "" || cfg.Gateway6 != "" || len(cfg.Routes) > 0 {
+		content += "      routes:\n"
+		if cfg.Gateway != "" {
+			content += netplanRoute(config.Route{To: "0.0.0.0/0", Via: cfg.Gateway, Metric: cfg.Metric})
+		}
+		if cfg.Gateway6 != "" {
+			content += netplanRoute(config.Route{To: "::/0", Via: cfg.Gateway6})
+		}
+		for _, route := range cfg.Routes {
+			content += netplanRoute(route)
+		}
+	}
+
+	// Add DNS servers and search domains
+	if len(cfg.DNS) > 0 || len(cfg.Search) > 0 {
+		content += "      nameservers:\n"
+		if len(cfg.DNS) > 0 {
+			content += "        addresses:\n"
+			for _, dns := range cfg.DNS {
+				content += fmt.Sprintf("          - %s\n", dns)
+			}
+		}
+		if len(cfg.Search) > 0 {
+			content += "        search:\n"
+			for _, domain := range cfg.Search {
+				content += fmt.Sprintf("          - %s\n", domain)
+			}
+		}
+	}
+
+	return content, nil
+}
+
+// netplanRoute formats a single netplan route list entry
+func netplanRoute(route config.Route) string {
+	entry := fmt.Sprintf("        - to: %s\n          via: %s\n", route.To, route.Via)
+	if route.Metric > 0 {
+		entry += fmt.Sprintf("          metric: %d\n", route.Metric)
+	}
+	if route.Table > 0 {
+		entry += fmt.Sprintf("          table: %d\n", route.Table)
+	}
+	return entry
+}
+
 // configureIfupdownSingle configures a single interface using ifupdown (backwards compat)
-func configureIfupdownSingle(cfg config.NetworkConfig) error {
-	// Find primary interface
-	ifaceName, err := getPrimaryInterface()
-	if err != nil {
-		return fmt.Errorf("failed to find primary interface: %w", err)
+// If ifaceName is empty, the primary interface is used
+func configureIfupdownSingle(ifaceName string, cfg config.NetworkConfig) error {
+	if ifaceName == "" {
+		var err error
+		ifaceName, err = getPrimaryInterface()
+		if err != nil {
+			return fmt.Errorf("failed to find primary interface: %w", err)
+		}
 	}
 
 	networks := map[string]config.NetworkConfig{
@@ -342,20 +609,51 @@ func configureIfupdownSingle(cfg config.NetworkConfig) error {
 func configureIfupdownAll(networks map[string]config.NetworkConfig) error {
 	content := "# Generated by Cyber Range Configuration Client\n"
 
-	for ifaceName, cfg := range networks {
+	for _, name := range config.OrderedNames(networks) {
+		cfg := networks[name]
+
+		// Bond and bridge members carry no addressing of their own
+		if parent, ok := config.ParentOf(networks, name); ok {
+			content += ifupdownMemberStanza(name, parent, networks[parent].Kind)
+			continue
+		}
+
+		// Renamed NICs get their new name on next boot via a .link file
+		ifaceName := name
+		if cfg.SetName != "" {
+			if cfg.MAC != "" {
+				if err := writeLinkFile(cfg.MAC, cfg.SetName); err != nil {
+					return err
+				}
+			}
+			ifaceName = cfg.SetName
+		}
+
+		addresses := cfg.IPv4Addresses()
+
 		if cfg.DHCP {
 			content += fmt.Sprintf("\nauto %s\niface %s inet dhcp\n", ifaceName, ifaceName)
+			content += ifupdownVirtualOptions(ifaceName, cfg)
+
+			if dns := cfg.IPv4DNS(); len(dns) > 0 {
+				content += fmt.Sprintf("    dns-nameservers %s\n", strings.Join(dns, " "))
+			}
+		} else if len(addresses) == 0 {
+			content += fmt.Sprintf("\nauto %s\niface %s inet manual\n", ifaceName, ifaceName)
+			content += ifupdownVirtualOptions(ifaceName, cfg)
 		} else {
 			// Parse CIDR address
-			ip, ipNet, err := net.ParseCIDR(cfg.Address)
+			ip, ipNet, err := net.ParseCIDR(addresses[0])
 			if err != nil {
 				return fmt.Errorf("invalid address format for %s: %w", ifaceName, err)
 			}
+			addresses = addresses[1:]
 
 			// Convert netmask to dotted decimal
 			mask := net.IP(ipNet.Mask).String()
 
 			content += fmt.Sprintf("\nauto %s\niface %s inet static\n", ifaceName, ifaceName)
+			content += ifupdownVirtualOptions(ifaceName, cfg)
 			content += fmt.Sprintf("    address %s\n", ip.String())
 			content += fmt.Sprintf("    netmask %s\n", mask)
 
@@ -363,15 +661,44 @@ func configureIfupdownAll(networks map[string]config.NetworkConfig) error {
 				content += fmt.Sprintf("    gateway %s\n", cfg.Gateway)
 			}
 
-			if len(cfg.DNS) > 0 {
-				content += fmt.Sprintf("    dns-nameservers %s\n", strings.Join(cfg.DNS, " "))
+			if dns := cfg.IPv4DNS(); len(dns) > 0 {
+				content += fmt.Sprintf("    dns-nameservers %s\n", strings.Join(dns, " "))
 			}
 		}
 
+		if cfg.Metric > 0 {
+			content += fmt.Sprintf("    metric %d\n", cfg.Metric)
+		}
+
+		if cfg.MTU > 0 {
+			content += fmt.Sprintf("    post-up ip link set dev %s mtu %d\n", ifaceName, cfg.MTU)
+		}
+
+		if len(cfg.Search) > 0 {
+			content += fmt.Sprintf("    dns-search %s\n", strings.Join(cfg.Search, " "))
+		}
+
 		// Add custom routes using post-up commands (works with both DHCP and static)
-		for _, route := range cfg.Routes {
-			content += fmt.Sprintf("    post-up ip route add %s via %s\n", route.To, route.Via)
-			content += fmt.Sprintf("    pre-down ip route del %s via %s\n", route.To, route.Via)
+		for _, route := range cfg.IPv4Routes() {
+			spec := ipRouteSpec(route)
+			content += fmt.Sprintf("    post-up ip route add %s\n", spec)
+			content += fmt.Sprintf("    pre-down ip route del %s\n", spec)
+		}
+
+		// Additional addresses get their own stanza on the same interface
+		for _, addr := range addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				return fmt.Errorf("invalid address format for %s: %w", ifaceName, err)
+			}
+			content += fmt.Sprintf("\niface %s inet static\n    address %s\n", ifaceName, addr)
+		}
+
+		if cfg.HasIPv6() {
+			stanza, err := ifupdownIPv6Stanzas(ifaceName, cfg)
+			if err != nil {
+				return err
+			}
+			content += stanza
 		}
 	}
 
@@ -400,6 +727,66 @@ func configureIfupdownAll(networks map[string]config.NetworkConfig) error {
 	return nil
 }
 
+// ifupdownIPv6Stanzas builds the inet6 stanzas for an interface
+// DHCPv6 uses "inet6 dhcp", SLAAC "inet6 auto"; static addresses get their own stanzas
+func ifupdownIPv6Stanzas(ifaceName string, cfg config.NetworkConfig) (string, error) {
+	addresses := cfg.IPv6Addresses()
+	for _, addr := range addresses {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return "", fmt.Errorf("invalid address format for %s: %w", ifaceName, err)
+		}
+	}
+
+	var content string
+	switch {
+	case cfg.DHCP6:
+		content += fmt.Sprintf("\niface %s inet6 dhcp\n", ifaceName)
+	case cfg.AcceptRA || len(addresses) == 0:
+		content += fmt.Sprintf("\niface %s inet6 auto\n", ifaceName)
+	default:
+		content += fmt.Sprintf("\niface %s inet6 static\n    address %s\n", ifaceName, addresses[0])
+		addresses = addresses[1:]
+		if cfg.Gateway6 != "" {
+			content += fmt.Sprintf("    gateway %s\n", cfg.Gateway6)
+		}
+	}
+
+	// With dynamic addressing a static gateway is added as a route instead
+	if (cfg.DHCP6 || cfg.AcceptRA || len(cfg.IPv6Addresses()) == 0) && cfg.Gateway6 != "" {
+		content += fmt.Sprintf("    post-up ip -6 route add default via %s dev %s\n", cfg.Gateway6, ifaceName)
+		content += fmt.Sprintf("    pre-down ip -6 route del default via %s dev %s\n", cfg.Gateway6, ifaceName)
+	}
+
+	if dns := cfg.IPv6DNS(); len(dns) > 0 {
+		content += fmt.Sprintf("    dns-nameservers %s\n", strings.Join(dns, " "))
+	}
+
+	for _, route := range cfg.IPv6Routes() {
+		spec := ipRouteSpec(route)
+		content += fmt.Sprintf("    post-up ip -6 route add %s\n", spec)
+		content += fmt.Sprintf("    pre-down ip -6 route del %s\n", spec)
+	}
+
+	// Additional addresses get their own stanza on the same interface
+	for _, addr := range addresses {
+		content += fmt.Sprintf("\niface %s inet6 static\n    address %s\n", ifaceName, addr)
+	}
+
+	return content, nil
+}
+
+// ipRouteSpec formats a route for the ip route command
+func ipRouteSpec(route config.Route) string {
+	spec := fmt.Sprintf("%s via %s", route.To, route.Via)
+	if route.Metric > 0 {
+		spec += fmt.Sprintf(" metric %d", route.Metric)
+	}
+	if route.Table > 0 {
+		spec += fmt.Sprintf(" table %d", route.Table)
+	}
+	return spec
+}
+
 // getPrimaryInterface returns the name of the primary network interface
 func getPrimaryInterface() (string, error) {
 	interfaces, err := net.Interfaces()
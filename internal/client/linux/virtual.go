@@ -0,0 +1,197 @@
+package linux
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cyber-range-config/internal/config"
+)
+
+// bondOptions maps netplan bond parameter names to kernel bonding option names
+var bondOptions = map[string]string{
+	"mode":                    "mode",
+	"mii-monitor-interval":    "miimon",
+	"lacp-rate":               "lacp_rate",
+	"transmit-hash-policy":    "xmit_hash_policy",
+	"primary":                 "primary",
+	"up-delay":                "updelay",
+	"down-delay":              "downdelay",
+	"min-links":               "min_links",
+	"arp-interval":            "arp_interval",
+	"arp-ip-targets":          "arp_ip_target",
+	"ad-select":               "ad_select",
+	"gratuitous-arp":          "num_grat_arp",
+	"all-slaves-active":       "all_slaves_active",
+	"fail-over-mac-policy":    "fail_over_mac",
+	"primary-reselect-policy": "primary_reselect",
+}
+
+// bridgeOptions maps netplan bridge parameter names to NetworkManager and ifupdown names
+var bridgeOptions = map[string]struct{ nm, ifupdown string }{
+	"stp":           {"bridge.stp", "bridge_stp"},
+	"forward-delay": {"bridge.forward-delay", "bridge_fd"},
+	"hello-time":    {"bridge.hello-time", "bridge_hello"},
+	"max-age":       {"bridge.max-age", "bridge_maxage"},
+	"priority":      {"bridge.priority", "bridge_bridgeprio"},
+	"ageing-time":   {"bridge.ageing-time", "bridge_ageing"},
+}
+
+// sortedParameters returns parameter keys in a stable order
+func sortedParameters(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bondKernelOptions translates netplan bond parameters to "name=value" kernel options
+func bondKernelOptions(params map[string]string) []string {
+	var options []string
+	for _, key := range sortedParameters(params) {
+		if name, ok := bondOptions[key]; ok {
+			options = append(options, fmt.Sprintf("%s=%s", name, params[key]))
+		}
+	}
+	return options
+}
+
+// switchValue renders a netplan boolean as the given on/off words
+func switchValue(value, on, off string) string {
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		if enabled {
+			return on
+		}
+		return off
+	}
+	return value
+}
+
+// ensureNMVirtualConnection creates or updates the connection for a bond, bridge or VLAN
+func ensureNMVirtualConnection(ifaceName string, cfg config.NetworkConfig) error {
+	var settings []string
+	switch cfg.Kind {
+	case config.KindBond:
+		if options := bondKernelOptions(cfg.Parameters); len(options) > 0 {
+			settings = append(settings, "bond.options", strings.Join(options, ","))
+		}
+	case config.KindBridge:
+		for _, key := range sortedParameters(cfg.Parameters) {
+			if opt, ok := bridgeOptions[key]; ok {
+				value := cfg.Parameters[key]
+				if key == "stp" {
+					value = switchValue(value, "yes", "no")
+				}
+				settings = append(settings, opt.nm, value)
+			}
+		}
+	case config.KindVLAN:
+		settings = append(settings, "vlan.parent", cfg.Link, "vlan.id", strconv.Itoa(cfg.VLANID))
+	}
+
+	connName, found, err := findNMConnection(ifaceName)
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	if found {
+		if len(settings) == 0 {
+			return nil
+		}
+		args = append([]string{"connection", "modify", connName}, settings...)
+	} else {
+		args = append([]string{"connection", "add", "type", cfg.Kind,
+			"con-name", nmConnectionName(ifaceName), "ifname", ifaceName}, settings...)
+	}
+
+	cmd := exec.Command("nmcli", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli %s failed: %s - %w", args[1], string(output), err)
+	}
+
+	return nil
+}
+
+// enslaveNMConnection makes a member's connection a port of its bond or bridge
+func enslaveNMConnection(ifaceName, parent, kind string) error {
+	connName, err := getNMConnectionForInterface(ifaceName)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("nmcli", "connection", "modify", connName,
+		"connection.master", parent,
+		"connection.slave-type", kind)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli modify failed: %s - %w", string(output), err)
+	}
+
+	return nil
+}
+
+// netplanVirtualKeys renders the device-specific netplan keys of a bond, bridge or VLAN
+func netplanVirtualKeys(cfg config.NetworkConfig) string {
+	var content string
+
+	if len(cfg.Members) > 0 {
+		content += fmt.Sprintf("      interfaces: [%s]\n", strings.Join(cfg.Members, ", "))
+	}
+
+	if len(cfg.Parameters) > 0 {
+		content += "      parameters:\n"
+		for _, key := range sortedParameters(cfg.Parameters) {
+			content += fmt.Sprintf("        %s: %s\n", key, cfg.Parameters[key])
+		}
+	}
+
+	if cfg.Kind == config.KindVLAN {
+		content += fmt.Sprintf("      id: %d\n      link: %s\n", cfg.VLANID, cfg.Link)
+	}
+
+	return content
+}
+
+// ifupdownVirtualOptions returns the stanza options that create a bond, bridge or VLAN
+// Bonds need the ifenslave package and bridges bridge-utils; VLANs are created with ip link
+func ifupdownVirtualOptions(ifaceName string, cfg config.NetworkConfig) string {
+	var content string
+
+	switch cfg.Kind {
+	case config.KindBond:
+		content += fmt.Sprintf("    bond-slaves %s\n", strings.Join(cfg.Members, " "))
+		for _, option := range bondKernelOptions(cfg.Parameters) {
+			name, value, _ := strings.Cut(option, "=")
+			content += fmt.Sprintf("    bond-%s %s\n", strings.ReplaceAll(name, "_", "-"), value)
+		}
+	case config.KindBridge:
+		content += fmt.Sprintf("    bridge_ports %s\n", strings.Join(cfg.Members, " "))
+		for _, key := range sortedParameters(cfg.Parameters) {
+			if opt, ok := bridgeOptions[key]; ok {
+				value := cfg.Parameters[key]
+				if key == "stp" {
+					value = switchValue(value, "on", "off")
+				}
+				content += fmt.Sprintf("    %s %s\n", opt.ifupdown, value)
+			}
+		}
+	case config.KindVLAN:
+		content += fmt.Sprintf("    pre-up ip link add link %s name %s type vlan id %d\n", cfg.Link, ifaceName, cfg.VLANID)
+		content += fmt.Sprintf("    post-down ip link delete %s\n", ifaceName)
+	}
+
+	return content
+}
+
+// ifupdownMemberStanza brings up a bond or bridge member without addressing
+func ifupdownMemberStanza(ifaceName, parent, kind string) string {
+	content := fmt.Sprintf("\nauto %s\niface %s inet manual\n", ifaceName, ifaceName)
+	if kind == config.KindBond {
+		content += fmt.Sprintf("    bond-master %s\n", parent)
+	}
+	return content
+}
@@ -0,0 +1,191 @@
+package openwrt
+
+import (
+	"fmt"
+	"regexp"
+
+	"cyber-range-config/internal/client/common"
+	"cyber-range-config/internal/config"
+)
+
+// invalidUCIChars matches characters not allowed in UCI section names
+var invalidUCIChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// bondingOptions maps netplan bond parameter names to proto-bonding options
+var bondingOptions = map[string]string{
+	"mode":                 "bonding_policy",
+	"mii-monitor-interval": "miimon",
+	"lacp-rate":            "lacp_rate",
+	"transmit-hash-policy": "xmit_hash_policy",
+	"primary":              "primary",
+	"up-delay":             "updelay",
+	"down-delay":           "downdelay",
+	"min-links":            "min_links",
+}
+
+// UCIName converts an interface name into a valid UCI section name
+func UCIName(name string) string {
+	return invalidUCIChars.ReplaceAllString(name, "_")
+}
+
+// InterfaceName returns the UCI interface to configure for a network entry
+// Ethernets are resolved by MAC; bonds, bridges and VLANs get their own interface
+func InterfaceName(name string, cfg config.NetworkConfig) string {
+	if cfg.IsVirtual() {
+		return UCIName(name)
+	}
+	return ResolveUCIInterface(name, cfg.MAC)
+}
+
+// ConfigureDevices writes the UCI device, bridge-vlan and bonding sections for
+// virtual devices and points their interfaces at them. Members are looked up by MAC.
+func ConfigureDevices(networks map[string]config.NetworkConfig) error {
+	for _, name := range config.OrderedNames(networks) {
+		cfg := networks[name]
+		if !cfg.IsVirtual() {
+			continue
+		}
+
+		var commands [][]string
+		switch cfg.Kind {
+		case config.KindBridge:
+			commands = bridgeCommands(name, cfg, networks)
+		case config.KindVLAN:
+			commands = vlanCommands(name, cfg, networks)
+		case config.KindBond:
+			commands = bondCommands(name, cfg, networks)
+		}
+
+		if err := runUCICommands(commands); err != nil {
+			return fmt.Errorf("failed to configure %s %s: %w", cfg.Kind, name, err)
+		}
+	}
+
+	return nil
+}
+
+// deviceName returns the Linux device name OpenWrt will use for a network entry
+func deviceName(name string, networks map[string]config.NetworkConfig) string {
+	cfg, ok := networks[name]
+	if !ok {
+		return name
+	}
+
+	switch cfg.Kind {
+	case config.KindBond:
+		// proto bonding names its device after the interface
+		return "bond-" + UCIName(name)
+	case config.KindVLAN:
+		if parent, ok := networks[cfg.Link]; ok && parent.Kind == config.KindBridge {
+			return fmt.Sprintf("%s.%d", deviceName(cfg.Link, networks), cfg.VLANID)
+		}
+		return name
+	case config.KindBridge:
+		return name
+	default:
+		return common.ResolveInterfaceName(name, cfg.MAC)
+	}
+}
+
+// interfaceDeviceCommands declares the UCI interface for a virtual device
+func interfaceDeviceCommands(name, device string) [][]string {
+	prefix := fmt.Sprintf("network.%s", UCIName(name))
+	return [][]string{
+		{"uci", "set", prefix + "=interface"},
+		{"uci", "set", fmt.Sprintf("%s.device=%s", prefix, device)},
+	}
+}
+
+// bridgeCommands builds a bridge device section with the members as ports
+func bridgeCommands(name string, cfg config.NetworkConfig, networks map[string]config.NetworkConfig) [][]string {
+	section := fmt.Sprintf("network.cr_%s_dev", UCIName(name))
+	commands := [][]string{
+		{"uci", "set", section + "=device"},
+		{"uci", "set", section + ".type=bridge"},
+		{"uci", "set", fmt.Sprintf("%s.name=%s", section, name)},
+		{"uci", "delete", section + ".ports"},
+	}
+
+	for _, member := range cfg.Members {
+		commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.ports=%s", section, deviceName(member, networks))})
+	}
+
+	if stp, ok := cfg.Parameters["stp"]; ok {
+		commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.stp=%s", section, switchValue(stp))})
+	}
+	if delay, ok := cfg.Parameters["forward-delay"]; ok {
+		commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.forward_delay=%s", section, delay)})
+	}
+	if priority, ok := cfg.Parameters["priority"]; ok {
+		commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.priority=%s", section, priority)})
+	}
+
+	return append(commands, interfaceDeviceCommands(name, name)...)
+}
+
+// vlanCommands builds either a bridge-vlan (VLAN on a bridge) or an 802.1q device section
+func vlanCommands(name string, cfg config.NetworkConfig, networks map[string]config.NetworkConfig) [][]string {
+	parent, ok := networks[cfg.Link]
+
+	// VLAN filtering on a bridge: tag the VLAN on every bridge port
+	if ok && parent.Kind == config.KindBridge {
+		bridge := deviceName(cfg.Link, networks)
+		section := fmt.Sprintf("network.cr_%s_vlan", UCIName(name))
+		commands := [][]string{
+			{"uci", "set", section + "=bridge-vlan"},
+			{"uci", "set", fmt.Sprintf("%s.device=%s", section, bridge)},
+			{"uci", "set", fmt.Sprintf("%s.vlan=%d", section, cfg.VLANID)},
+			{"uci", "delete", section + ".ports"},
+		}
+		for _, member := range parent.Members {
+			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.ports=%s:t", section, deviceName(member, networks))})
+		}
+		return append(commands, interfaceDeviceCommands(name, deviceName(name, networks))...)
+	}
+
+	section := fmt.Sprintf("network.cr_%s_dev", UCIName(name))
+	commands := [][]string{
+		{"uci", "set", section + "=device"},
+		{"uci", "set", section + ".type=8021q"},
+		{"uci", "set", fmt.Sprintf("%s.ifname=%s", section, deviceName(cfg.Link, networks))},
+		{"uci", "set", fmt.Sprintf("%s.vid=%d", section, cfg.VLANID)},
+		{"uci", "set", fmt.Sprintf("%s.name=%s", section, name)},
+	}
+	return append(commands, interfaceDeviceCommands(name, name)...)
+}
+
+// bondCommands builds a proto bonding interface (needs the proto-bonding package)
+func bondCommands(name string, cfg config.NetworkConfig, networks map[string]config.NetworkConfig) [][]string {
+	prefix := fmt.Sprintf("network.%s", UCIName(name))
+	commands := [][]string{
+		{"uci", "set", prefix + "=interface"},
+		{"uci", "set", prefix + ".proto=bonding"},
+		{"uci", "delete", prefix + ".slaves"},
+	}
+
+	for _, member := range cfg.Members {
+		commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.slaves=%s", prefix, deviceName(member, networks))})
+	}
+
+	for key, value := range cfg.Parameters {
+		if option, ok := bondingOptions[key]; ok {
+			commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.%s=%s", prefix, option, value)})
+		}
+	}
+	if _, ok := cfg.Parameters["mii-monitor-interval"]; ok {
+		commands = append(commands, []string{"uci", "set", prefix + ".link_monitoring=mii"})
+	}
+
+	return commands
+}
+
+// switchValue renders a netplan boolean as UCI 1/0
+func switchValue(value string) string {
+	switch value {
+	case "true", "yes", "on":
+		return "1"
+	case "false", "no", "off":
+		return "0"
+	}
+	return value
+}
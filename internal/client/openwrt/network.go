@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"cyber-range-config/internal/client/common"
 	"cyber-range-config/internal/config"
 )
 
@@ -39,21 +40,186 @@ func MapInterfaceName(cloudInitName string) string {
 	return "lan" + name
 }
 
+// ResolveUCIInterface finds the UCI interface for a network entry
+// The MAC is resolved to a local device and looked up in the UCI network config;
+// if that fails the static InterfaceMapping is used
+func ResolveUCIInterface(cloudInitName, mac string) string {
+	if mac != "" {
+		if device, err := common.GetInterfaceByMAC(mac); err == nil {
+			if uciName, err := findUCIInterfaceForDevice(device); err == nil {
+				return uciName
+			}
+		}
+	}
+	return MapInterfaceName(cloudInitName)
+}
+
+// uciNetwork is a parsed view of `uci show network`
+type uciNetwork struct {
+	sectionTypes map[string]string
+	options      map[string]map[string][]string
+}
+
+// readUCINetwork parses the output of `uci show network`
+func readUCINetwork() (*uciNetwork, error) {
+	output, err := exec.Command("uci", "show", "network").Output()
+	if err != nil {
+		return nil, fmt.Errorf("uci show network failed: %w", err)
+	}
+
+	n := &uciNetwork{
+		sectionTypes: make(map[string]string),
+		options:      make(map[string]map[string][]string),
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(key, "network."), ".", 2)
+		if len(parts) == 1 {
+			n.sectionTypes[parts[0]] = value
+			continue
+		}
+		if n.options[parts[0]] == nil {
+			n.options[parts[0]] = make(map[string][]string)
+		}
+		n.options[parts[0]][parts[1]] = parseUCIList(value)
+	}
+
+	return n, nil
+}
+
+// findUCIInterfaceForDevice returns the UCI interface whose device (or bridge) contains the given device
+func findUCIInterfaceForDevice(device string) (string, error) {
+	n, err := readUCINetwork()
+	if err != nil {
+		return "", err
+	}
+
+	// Devices that carry this NIC: itself, plus any bridge it is a port of
+	candidates := []string{device}
+	for section, typ := range n.sectionTypes {
+		if typ != "device" {
+			continue
+		}
+		for _, port := range n.options[section]["ports"] {
+			if port == device {
+				candidates = append(candidates, n.options[section]["name"]...)
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		for section, typ := range n.sectionTypes {
+			if typ != "interface" {
+				continue
+			}
+			for _, opt := range []string{"device", "ifname"} {
+				for _, dev := range n.options[section][opt] {
+					if dev == candidate {
+						return section, nil
+					}
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no UCI interface uses device %s", device)
+}
+
+// parseUCIList splits a uci show value like 'eth0' 'eth1' into its items
+func parseUCIList(value string) []string {
+	var items []string
+	for _, field := range strings.Fields(value) {
+		items = append(items, strings.Trim(field, "'"))
+	}
+	return items
+}
+
 // ConfigureNetwork applies network configuration to lan interface (legacy)
 func ConfigureNetwork(cfg config.NetworkConfig) error {
 	return ConfigureInterface("lan", cfg)
 }
 
 // ConfigureInterface applies network configuration to a specific UCI interface
+// IPv6 settings go on a companion "<iface>6" interface, like OpenWrt's own wan/wan6
+// Bonds keep proto bonding, which only supports static IPv4 addressing
 func ConfigureInterface(uciInterface string, cfg config.NetworkConfig) error {
-	if cfg.DHCP {
-		return configureInterfaceDHCP(uciInterface)
+	var err error
+	switch {
+	case cfg.Kind == config.KindBond && cfg.DHCP:
+		return fmt.Errorf("proto bonding does not support DHCP on %s", uciInterface)
+	case cfg.Kind == config.KindBond && len(cfg.IPv4Addresses()) == 0:
+		// The bond is already up from ConfigureDevices; nothing to address
+	case cfg.DHCP:
+		err = configureInterfaceDHCP(uciInterface, cfg)
+	case len(cfg.IPv4Addresses()) == 0 && cfg.HasIPv6():
+		// IPv6-only: bring the device up without IPv4
+		err = runUCICommands([][]string{{"uci", "set", fmt.Sprintf("network.%s.proto=none", uciInterface)}})
+	case cfg.Kind == config.KindBond:
+		err = configureInterfaceStatic(uciInterface, "bonding", cfg)
+	default:
+		err = configureInterfaceStatic(uciInterface, "static", cfg)
+	}
+	if err != nil {
+		return err
 	}
-	return configureInterfaceStatic(uciInterface, cfg)
+
+	if cfg.HasIPv6() {
+		return configureInterfaceIPv6(uciInterface, cfg)
+	}
+	return nil
+}
+
+// configureInterfaceIPv6 writes the "<iface>6" alias interface carrying IPv6
+// DHCPv6 and SLAAC use proto dhcpv6 (SLAAC-only skips address requests); static uses ip6addr/ip6gw
+func configureInterfaceIPv6(uciInterface string, cfg config.NetworkConfig) error {
+	name := uciInterface + "6"
+	prefix := fmt.Sprintf("network.%s", name)
+
+	commands := [][]string{
+		{"uci", "set", prefix + "=interface"},
+		{"uci", "set", fmt.Sprintf("%s.device=@%s", prefix, uciInterface)},
+		{"uci", "delete", prefix + ".ip6addr"},
+		{"uci", "delete", prefix + ".ip6gw"},
+		{"uci", "delete", prefix + ".reqaddress"},
+		{"uci", "delete", prefix + ".dns"},
+	}
+
+	addresses := cfg.IPv6Addresses()
+	switch {
+	case cfg.DHCP6:
+		commands = append(commands, []string{"uci", "set", prefix + ".proto=dhcpv6"})
+	case cfg.AcceptRA || len(addresses) == 0:
+		commands = append(commands,
+			[]string{"uci", "set", prefix + ".proto=dhcpv6"},
+			[]string{"uci", "set", prefix + ".reqaddress=none"},
+		)
+	default:
+		commands = append(commands, []string{"uci", "set", prefix + ".proto=static"})
+		for _, addr := range addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				return fmt.Errorf("invalid address format: %w", err)
+			}
+			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.ip6addr=%s", prefix, addr)})
+		}
+		if cfg.Gateway6 != "" {
+			commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.ip6gw=%s", prefix, cfg.Gateway6)})
+		}
+	}
+
+	for _, dns := range cfg.IPv6DNS() {
+		commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.dns=%s", prefix, dns)})
+	}
+
+	commands = append(commands, routeCommands(name, "route6", cfg.IPv6Routes())...)
+
+	return runUCICommands(commands)
 }
 
 // configureInterfaceDHCP sets a specific interface to use DHCP
-func configureInterfaceDHCP(uciInterface string) error {
+func configureInterfaceDHCP(uciInterface string, cfg config.NetworkConfig) error {
 	prefix := fmt.Sprintf("network.%s", uciInterface)
 	commands := [][]string{
 		{"uci", "set", prefix + ".proto=dhcp"},
@@ -61,41 +227,61 @@ func configureInterfaceDHCP(uciInterface string) error {
 		{"uci", "delete", prefix + ".netmask"},
 		{"uci", "delete", prefix + ".gateway"},
 		{"uci", "delete", prefix + ".dns"},
+		{"uci", "delete", prefix + ".peerdns"},
 	}
 
-	for _, args := range commands {
-		cmd := exec.Command(args[0], args[1:]...)
-		// Ignore errors for delete commands (key might not exist)
-		if args[1] != "delete" {
-			if output, err := cmd.CombinedOutput(); err != nil {
-				return fmt.Errorf("failed to run %v: %s - %w", args, string(output), err)
-			}
-		} else {
-			cmd.Run() // Ignore error for delete
+	// Configured DNS servers replace the ones the DHCP server hands out
+	if dns := cfg.IPv4DNS(); len(dns) > 0 {
+		commands = append(commands, []string{"uci", "set", prefix + ".peerdns=0"})
+		for _, server := range dns {
+			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.dns=%s", prefix, server)})
 		}
 	}
 
-	return nil
+	commands = append(commands, interfaceOptionCommands(uciInterface, cfg)...)
+
+	return runUCICommands(commands)
 }
 
 // configureInterfaceStatic sets a static IP configuration on a specific interface
-func configureInterfaceStatic(uciInterface string, cfg config.NetworkConfig) error {
-	// Parse CIDR address
-	ip, ipNet, err := net.ParseCIDR(cfg.Address)
-	if err != nil {
-		return fmt.Errorf("invalid address format: %w", err)
+func configureInterfaceStatic(uciInterface, proto string, cfg config.NetworkConfig) error {
+	addresses := cfg.IPv4Addresses()
+	if len(addresses) == 0 {
+		return fmt.Errorf("invalid address format: no IPv4 address for %s", uciInterface)
 	}
 
-	// Convert subnet mask to dotted decimal
-	mask := net.IP(ipNet.Mask).String()
-
 	prefix := fmt.Sprintf("network.%s", uciInterface)
 
 	// Set static IP configuration
 	commands := [][]string{
-		{"uci", "set", prefix + ".proto=static"},
-		{"uci", "set", fmt.Sprintf("%s.ipaddr=%s", prefix, ip.String())},
-		{"uci", "set", fmt.Sprintf("%s.netmask=%s", prefix, mask)},
+		{"uci", "set", fmt.Sprintf("%s.proto=%s", prefix, proto)},
+		{"uci", "delete", prefix + ".ipaddr"},
+		{"uci", "delete", prefix + ".netmask"},
+		{"uci", "delete", prefix + ".dns"},
+	}
+
+	if len(addresses) == 1 {
+		// Parse CIDR address
+		ip, ipNet, err := net.ParseCIDR(addresses[0])
+		if err != nil {
+			return fmt.Errorf("invalid address format: %w", err)
+		}
+
+		// Convert subnet mask to dotted decimal
+		mask := net.IP(ipNet.Mask).String()
+
+		commands = append(commands,
+			[]string{"uci", "set", fmt.Sprintf("%s.ipaddr=%s", prefix, ip.String())},
+			[]string{"uci", "set", fmt.Sprintf("%s.netmask=%s", prefix, mask)},
+		)
+	} else {
+		// Multiple addresses use the CIDR list form of ipaddr
+		for _, addr := range addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				return fmt.Errorf("invalid address format: %w", err)
+			}
+			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.ipaddr=%s", prefix, addr)})
+		}
 	}
 
 	// Add gateway if specified
@@ -104,18 +290,91 @@ func configureInterfaceStatic(uciInterface string, cfg config.NetworkConfig) err
 	}
 
 	// Add DNS servers if specified
-	if len(cfg.DNS) > 0 {
-		// Clear existing DNS first
-		commands = append(commands, []string{"uci", "delete", prefix + ".dns"})
-		// Add each DNS server
-		for _, dns := range cfg.DNS {
-			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.dns=%s", prefix, dns)})
+	for _, dns := range cfg.IPv4DNS() {
+		commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.dns=%s", prefix, dns)})
+	}
+
+	commands = append(commands, interfaceOptionCommands(uciInterface, cfg)...)
+
+	return runUCICommands(commands)
+}
+
+// interfaceOptionCommands builds the UCI commands shared by DHCP and static interfaces:
+// MTU, default route metric, DNS search domains and custom routes
+func interfaceOptionCommands(uciInterface string, cfg config.NetworkConfig) [][]string {
+	prefix := fmt.Sprintf("network.%s", uciInterface)
+	var commands [][]string
+
+	if cfg.MTU > 0 {
+		commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.mtu=%d", prefix, cfg.MTU)})
+	}
+
+	if cfg.Metric > 0 {
+		commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.metric=%d", prefix, cfg.Metric)})
+	}
+
+	if len(cfg.Search) > 0 {
+		commands = append(commands, []string{"uci", "delete", prefix + ".dns_search"})
+		for _, domain := range cfg.Search {
+			commands = append(commands, []string{"uci", "add_list", fmt.Sprintf("%s.dns_search=%s", prefix, domain)})
+		}
+	}
+
+	commands = append(commands, routeCommands(uciInterface, "route", cfg.IPv4Routes())...)
+
+	return commands
+}
+
+// routeCommands replaces the route (or route6) sections we own for an interface
+func routeCommands(uciInterface, sectionType string, routes []config.Route) [][]string {
+	// Remove routes written by a previous run
+	commands := staleRouteCommands(uciInterface)
+
+	for i, route := range routes {
+		section := fmt.Sprintf("network.%s", routeSectionName(uciInterface, i))
+		commands = append(commands,
+			[]string{"uci", "set", section + "=" + sectionType},
+			[]string{"uci", "set", fmt.Sprintf("%s.interface=%s", section, uciInterface)},
+			[]string{"uci", "set", fmt.Sprintf("%s.target=%s", section, route.To)},
+			[]string{"uci", "set", fmt.Sprintf("%s.gateway=%s", section, route.Via)},
+		)
+		if route.Metric > 0 {
+			commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.metric=%d", section, route.Metric)})
+		}
+		if route.Table > 0 {
+			commands = append(commands, []string{"uci", "set", fmt.Sprintf("%s.table=%d", section, route.Table)})
 		}
 	}
 
+	return commands
+}
+
+// routeSectionName names the UCI route sections we own for an interface
+func routeSectionName(uciInterface string, index int) string {
+	return fmt.Sprintf("cr_%s_route%d", uciInterface, index)
+}
+
+// staleRouteCommands deletes route sections from earlier runs for an interface
+func staleRouteCommands(uciInterface string) [][]string {
+	n, err := readUCINetwork()
+	if err != nil {
+		return nil
+	}
+
+	var commands [][]string
+	prefix := fmt.Sprintf("cr_%s_route", uciInterface)
+	for section := range n.sectionTypes {
+		if strings.HasPrefix(section, prefix) {
+			commands = append(commands, []string{"uci", "delete", "network." + section})
+		}
+	}
+	return commands
+}
+
+// runUCICommands runs uci commands in order, ignoring failures of deletes (key might not exist)
+func runUCICommands(commands [][]string) error {
 	for _, args := range commands {
 		cmd := exec.Command(args[0], args[1:]...)
-		// Ignore errors for delete commands (key might not exist)
 		if len(args) > 1 && args[1] == "delete" {
 			cmd.Run() // Ignore error
 			continue
@@ -124,7 +383,6 @@ func configureInterfaceStatic(uciInterface string, cfg config.NetworkConfig) err
 			return fmt.Errorf("failed to run %v: %s - %w", args, string(output), err)
 		}
 	}
-
 	return nil
 }
 
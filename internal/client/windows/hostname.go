@@ -2,13 +2,18 @@ package windows
 
 import (
 	"fmt"
+	"strings"
 	"syscall"
 	"unsafe"
+
+	"cyber-range-config/internal/config"
 )
 
 const (
 	// ComputerNamePhysicalDnsHostname for SetComputerNameExW
 	ComputerNamePhysicalDnsHostname = 5
+	// ComputerNamePhysicalDnsDomain for SetComputerNameExW (primary DNS suffix)
+	ComputerNamePhysicalDnsDomain = 6
 )
 
 var (
@@ -16,17 +21,40 @@ var (
 	procSetComputerNameEx = kernel32.NewProc("SetComputerNameExW")
 )
 
-// SetHostname sets the Windows computer hostname
+// SetHostname sets the Windows computer hostname, and the primary DNS suffix from fqdn if set
+// Names over 15 characters are refused: Windows truncates the NetBIOS name, which breaks domain joins
 // Change takes effect after reboot
-func SetHostname(hostname string) error {
-	hostnamePtr, err := syscall.UTF16PtrFromString(hostname)
+func SetHostname(hostname, fqdn string) error {
+	if err := config.ValidateHostname(hostname, config.MaxNetBIOSLength); err != nil {
+		return err
+	}
+
+	if err := setComputerName(ComputerNamePhysicalDnsHostname, hostname); err != nil {
+		return err
+	}
+
+	if _, domain, ok := strings.Cut(fqdn, "."); ok {
+		if err := config.ValidateFQDN(fqdn); err != nil {
+			return err
+		}
+		if err := setComputerName(ComputerNamePhysicalDnsDomain, domain); err != nil {
+			return fmt.Errorf("failed to set DNS suffix: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// setComputerName calls SetComputerNameExW for one name type
+func setComputerName(nameType uintptr, name string) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
 	if err != nil {
 		return fmt.Errorf("failed to convert hostname: %w", err)
 	}
 
 	ret, _, err := procSetComputerNameEx.Call(
-		uintptr(ComputerNamePhysicalDnsHostname),
-		uintptr(unsafe.Pointer(hostnamePtr)),
+		nameType,
+		uintptr(unsafe.Pointer(namePtr)),
 	)
 
 	if ret == 0 {
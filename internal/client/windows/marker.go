@@ -14,6 +14,10 @@ const (
 	MarkerFile = ".configured"
 	// LogFile is the log file name
 	LogFile = "config.log"
+	// MetadataFile holds the instance metadata from the server as JSON
+	MetadataFile = "metadata.json"
+	// TokenFile holds a bootstrap token provisioned by hand; forge passes it in SMBIOS instead
+	TokenFile = "token"
 )
 
 // EnsureMarkerDir creates the marker directory if needed
@@ -48,3 +52,13 @@ func CreateMarker(hostname string) error {
 func GetLogPath() string {
 	return filepath.Join(MarkerDir, LogFile)
 }
+
+// GetTokenPath returns the path to the bootstrap token file
+func GetTokenPath() string {
+	return filepath.Join(MarkerDir, TokenFile)
+}
+
+// GetMetadataPath returns the path to the metadata file
+func GetMetadataPath() string {
+	return filepath.Join(MarkerDir, MetadataFile)
+}
@@ -6,31 +6,57 @@ import (
 	"os/exec"
 	"strings"
 
+	"cyber-range-config/internal/client/common"
 	"cyber-range-config/internal/config"
 )
 
 // ConfigureNetwork applies network configuration using netsh
+// The adapter is chosen by cfg.MAC when set, otherwise the primary adapter is used
 func ConfigureNetwork(cfg config.NetworkConfig) error {
-	adapterName, err := getPrimaryAdapterName()
+	var err error
+	adapterName := common.ResolveInterfaceName("", cfg.MAC)
+	if adapterName == "" {
+		adapterName, err = getPrimaryAdapterName()
+		if err != nil {
+			return fmt.Errorf("failed to find network adapter: %w", err)
+		}
+	}
+
+	switch {
+	case cfg.DHCP:
+		err = configureDHCP(adapterName, cfg)
+	case len(cfg.IPv4Addresses()) == 0 && cfg.HasIPv6():
+		// IPv6-only interface, leave IPv4 alone
+	default:
+		err = configureStatic(adapterName, cfg)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to find network adapter: %w", err)
+		return err
 	}
 
-	if cfg.DHCP {
-		return configureDHCP(adapterName)
+	if err := addRoutes(adapterName, "ipv4", cfg.IPv4Routes()); err != nil {
+		return err
 	}
 
-	return configureStatic(adapterName, cfg)
+	if cfg.HasIPv6() {
+		return configureIPv6(adapterName, cfg)
+	}
+	return nil
 }
 
-// configureDHCP sets the adapter to use DHCP
-func configureDHCP(adapterName string) error {
+// configureDHCP sets the adapter to use DHCP, keeping any DNS servers from cfg
+func configureDHCP(adapterName string, cfg config.NetworkConfig) error {
 	// Set IP to DHCP
 	cmd := exec.Command("netsh", "interface", "ip", "set", "address", adapterName, "dhcp")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to set DHCP for IP: %s - %w", string(output), err)
 	}
 
+	// Configured DNS servers replace the ones the DHCP server hands out
+	if dns := cfg.IPv4DNS(); len(dns) > 0 {
+		return setDNS(adapterName, dns)
+	}
+
 	// Set DNS to DHCP
 	cmd = exec.Command("netsh", "interface", "ip", "set", "dns", adapterName, "dhcp")
 	if output, err := cmd.CombinedOutput(); err != nil {
@@ -42,8 +68,13 @@ func configureDHCP(adapterName string) error {
 
 // configureStatic sets a static IP configuration
 func configureStatic(adapterName string, cfg config.NetworkConfig) error {
+	addresses := cfg.IPv4Addresses()
+	if len(addresses) == 0 {
+		return fmt.Errorf("invalid address format: no IPv4 address")
+	}
+
 	// Parse CIDR address
-	ip, ipNet, err := net.ParseCIDR(cfg.Address)
+	ip, ipNet, err := net.ParseCIDR(addresses[0])
 	if err != nil {
 		return fmt.Errorf("invalid address format: %w", err)
 	}
@@ -58,25 +89,109 @@ func configureStatic(adapterName string, cfg config.NetworkConfig) error {
 		return fmt.Errorf("failed to set static IP: %s - %w", string(output), err)
 	}
 
+	// Add secondary addresses
+	for _, addr := range addresses[1:] {
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return fmt.Errorf("invalid address format: %w", err)
+		}
+		cmd = exec.Command("netsh", "interface", "ip", "add", "address",
+			adapterName, ip.String(), net.IP(ipNet.Mask).String())
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add address %s: %s - %w", addr, string(output), err)
+		}
+	}
+
 	// Set DNS servers
-	if len(cfg.DNS) > 0 {
-		// Set primary DNS
-		cmd = exec.Command("netsh", "interface", "ip", "set", "dns",
-			adapterName, "static", cfg.DNS[0])
+	if dns := cfg.IPv4DNS(); len(dns) > 0 {
+		return setDNS(adapterName, dns)
+	}
+
+	return nil
+}
+
+// setDNS sets the adapter's IPv4 DNS servers statically, in order
+func setDNS(adapterName string, dns []string) error {
+	// Set primary DNS
+	cmd := exec.Command("netsh", "interface", "ip", "set", "dns",
+		adapterName, "static", dns[0])
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set primary DNS: %s - %w", string(output), err)
+	}
+
+	// Add additional DNS servers
+	for i := 1; i < len(dns); i++ {
+		cmd = exec.Command("netsh", "interface", "ip", "add", "dns",
+			adapterName, dns[i], fmt.Sprintf("index=%d", i+1))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add DNS %s: %s - %w", dns[i], string(output), err)
+		}
+	}
+
+	return nil
+}
+
+// configureIPv6 applies IPv6 settings with netsh interface ipv6
+// DHCPv6 sets the managed flag, SLAAC only enables router discovery
+func configureIPv6(adapterName string, cfg config.NetworkConfig) error {
+	if cfg.DHCP6 || cfg.AcceptRA {
+		managed := "disabled"
+		if cfg.DHCP6 {
+			managed = "enabled"
+		}
+		cmd := exec.Command("netsh", "interface", "ipv6", "set", "interface", adapterName,
+			"routerdiscovery=enabled", "managedaddress="+managed, "otherstateful="+managed)
 		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to set primary DNS: %s - %w", string(output), err)
+			return fmt.Errorf("failed to enable IPv6 autoconfiguration: %s - %w", string(output), err)
 		}
+	}
 
-		// Add additional DNS servers
-		for i := 1; i < len(cfg.DNS); i++ {
-			cmd = exec.Command("netsh", "interface", "ip", "add", "dns",
-				adapterName, cfg.DNS[i], fmt.Sprintf("index=%d", i+1))
-			if output, err := cmd.CombinedOutput(); err != nil {
-				return fmt.Errorf("failed to add DNS %s: %s - %w", cfg.DNS[i], string(output), err)
-			}
+	for _, addr := range cfg.IPv6Addresses() {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return fmt.Errorf("invalid address format: %w", err)
 		}
+		cmd := exec.Command("netsh", "interface", "ipv6", "add", "address", adapterName, addr)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add IPv6 address %s: %s - %w", addr, string(output), err)
+		}
+	}
+
+	routes := cfg.IPv6Routes()
+	if cfg.Gateway6 != "" {
+		routes = append([]config.Route{{To: "::/0", Via: cfg.Gateway6}}, routes...)
+	}
+	if err := addRoutes(adapterName, "ipv6", routes); err != nil {
+		return err
 	}
 
+	// Set DNS servers
+	for i, dns := range cfg.IPv6DNS() {
+		var cmd *exec.Cmd
+		if i == 0 {
+			cmd = exec.Command("netsh", "interface", "ipv6", "set", "dnsservers", adapterName, "static", dns, "primary")
+		} else {
+			cmd = exec.Command("netsh", "interface", "ipv6", "add", "dnsservers", adapterName, dns, fmt.Sprintf("index=%d", i+1))
+		}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set IPv6 DNS %s: %s - %w", dns, string(output), err)
+		}
+	}
+
+	return nil
+}
+
+// addRoutes adds routes via netsh for the given family ("ipv4" or "ipv6")
+func addRoutes(adapterName, family string, routes []config.Route) error {
+	for _, route := range routes {
+		args := []string{"interface", family, "add", "route", route.To, adapterName, route.Via}
+		if route.Metric > 0 {
+			args = append(args, fmt.Sprintf("metric=%d", route.Metric))
+		}
+		cmd := exec.Command("netsh", args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add route %s: %s - %w", route.To, string(output), err)
+		}
+	}
 	return nil
 }
 
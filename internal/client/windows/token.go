@@ -0,0 +1,41 @@
+package windows
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"cyber-range-config/internal/client/common"
+	"cyber-range-config/internal/token"
+)
+
+// LoadToken reads the bootstrap token from file, or when file is empty from the
+// token file in MarkerDir or the SMBIOS OEM string forge sets on the VM.
+// Returns "" if there is no token (yet)
+func LoadToken(file string) (string, error) {
+	if file == "" {
+		file = GetTokenPath()
+		if _, err := os.Stat(file); err != nil {
+			return readSMBIOSToken()
+		}
+	}
+	return common.LoadToken(file)
+}
+
+// readSMBIOSToken returns the bootstrap token among the VM's SMBIOS OEM strings, or ""
+func readSMBIOSToken() (string, error) {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		"(Get-CimInstance -ClassName Win32_ComputerSystem).OEMStringArray")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read SMBIOS OEM strings: %s - %w", string(output), err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if secret, ok := strings.CutPrefix(strings.TrimSpace(line), token.SMBIOSPrefix); ok {
+			return secret, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hostname length limits
+const (
+	MaxHostnameLength = 63  // One DNS label; also the Linux kernel limit
+	MaxFQDNLength     = 253 // Whole DNS name
+	MaxNetBIOSLength  = 15  // Windows computer names; longer ones break domain joins
+)
+
+// ValidateHostname checks that name is a single DNS label of at most maxLength characters
+func ValidateHostname(name string, maxLength int) error {
+	if name == "" {
+		return fmt.Errorf("hostname is empty")
+	}
+	if len(name) > maxLength {
+		return fmt.Errorf("hostname %q is %d characters, over the limit of %d", name, len(name), maxLength)
+	}
+	return validateLabel(name)
+}
+
+// ValidateFQDN checks that every label of fqdn is valid and the name fits in DNS
+func ValidateFQDN(fqdn string) error {
+	if len(fqdn) > MaxFQDNLength {
+		return fmt.Errorf("FQDN %q is %d characters, over the limit of %d", fqdn, len(fqdn), MaxFQDNLength)
+	}
+	for _, label := range strings.Split(fqdn, ".") {
+		if err := ValidateHostname(label, MaxHostnameLength); err != nil {
+			return fmt.Errorf("FQDN %q: %w", fqdn, err)
+		}
+	}
+	return nil
+}
+
+// SanitizeHostname turns name into a valid DNS label of at most maxLength characters:
+// invalid characters become hyphens and outer hyphens are trimmed. Returns "" if nothing is left
+func SanitizeHostname(name string, maxLength int) string {
+	label := []byte(name)
+	for i, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			label[i] = '-'
+		}
+	}
+	if len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	return strings.Trim(string(label), "-")
+}
+
+// validateLabel checks a DNS label for letters, digits and inner hyphens only
+func validateLabel(label string) error {
+	for i, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' && i > 0 && i < len(label)-1:
+		default:
+			return fmt.Errorf("hostname %q may only contain letters, digits and inner hyphens", label)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import "strings"
+
+// ConfigValue returns the effective value of an instance config key, taking
+// profile-level settings into account when LXD provided expanded_config
+func (i *LXDInstance) ConfigValue(key string) string {
+	if value, ok := i.ExpandedConfig[key]; ok {
+		return value
+	}
+	return i.Config[key]
+}
+
+// EffectiveConfig returns config merged into expanded_config, expanded values winning
+func (i *LXDInstance) EffectiveConfig() map[string]string {
+	if len(i.ExpandedConfig) == 0 {
+		return i.Config
+	}
+
+	cfg := make(map[string]string, len(i.ExpandedConfig)+len(i.Config))
+	for key, value := range i.Config {
+		cfg[key] = value
+	}
+	for key, value := range i.ExpandedConfig {
+		cfg[key] = value
+	}
+	return cfg
+}
+
+// IsWindows reports whether the instance was created from a Windows image,
+// going by the image.os and image.description keys LXD copies from the image
+func (i *LXDInstance) IsWindows() bool {
+	for _, key := range []string{"image.os", "image.description"} {
+		if strings.Contains(strings.ToLower(i.ConfigValue(key)), "windows") {
+			return true
+		}
+	}
+	return false
+}
+
+// NICHwaddrs returns the lowercased MAC of each NIC keyed by device name: the
+// hwaddr pinned on the expanded device if set, else volatile.<dev>.hwaddr
+func (i *LXDInstance) NICHwaddrs() map[string]string {
+	macs := make(map[string]string)
+	for key, value := range i.EffectiveConfig() {
+		if strings.HasPrefix(key, "volatile.") && strings.HasSuffix(key, ".hwaddr") && value != "" {
+			device := strings.TrimSuffix(strings.TrimPrefix(key, "volatile."), ".hwaddr")
+			macs[device] = strings.ToLower(value)
+		}
+	}
+	for device, settings := range i.ExpandedDevices {
+		if settings["type"] == "nic" && settings["hwaddr"] != "" {
+			macs[device] = strings.ToLower(settings["hwaddr"])
+		}
+	}
+	return macs
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// AllAddresses returns every configured address, falling back to Address for older servers
+func (n NetworkConfig) AllAddresses() []string {
+	if len(n.Addresses) > 0 {
+		return n.Addresses
+	}
+	if n.Address != "" {
+		return []string{n.Address}
+	}
+	return nil
+}
+
+// IPv4Addresses returns the configured IPv4 addresses in CIDR format
+func (n NetworkConfig) IPv4Addresses() []string {
+	var addrs []string
+	for _, addr := range n.AllAddresses() {
+		if !IsIPv6(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// IPv6Addresses returns the configured IPv6 addresses in CIDR format
+func (n NetworkConfig) IPv6Addresses() []string {
+	var addrs []string
+	for _, addr := range n.AllAddresses() {
+		if IsIPv6(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// IPv4Routes returns the custom routes with an IPv4 destination
+func (n NetworkConfig) IPv4Routes() []Route {
+	var routes []Route
+	for _, route := range n.Routes {
+		if !IsIPv6(route.To) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// IPv6Routes returns the custom routes with an IPv6 destination
+func (n NetworkConfig) IPv6Routes() []Route {
+	var routes []Route
+	for _, route := range n.Routes {
+		if IsIPv6(route.To) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// IPv4DNS returns the IPv4 DNS servers
+func (n NetworkConfig) IPv4DNS() []string {
+	var servers []string
+	for _, dns := range n.DNS {
+		if !IsIPv6(dns) {
+			servers = append(servers, dns)
+		}
+	}
+	return servers
+}
+
+// IPv6DNS returns the IPv6 DNS servers
+func (n NetworkConfig) IPv6DNS() []string {
+	var servers []string
+	for _, dns := range n.DNS {
+		if IsIPv6(dns) {
+			servers = append(servers, dns)
+		}
+	}
+	return servers
+}
+
+// HasIPv6 reports whether the interface has any IPv6 configuration
+func (n NetworkConfig) HasIPv6() bool {
+	return n.DHCP6 || n.AcceptRA || n.Gateway6 != "" || len(n.IPv6Addresses()) > 0
+}
+
+// IsIPv6 reports whether an address, CIDR or route destination is IPv6
+func IsIPv6(addr string) bool {
+	host := addr
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() == nil
+	}
+	return strings.Contains(addr, ":")
+}
+
+// IsVirtual reports whether the interface is a bond, bridge or VLAN
+func (n NetworkConfig) IsVirtual() bool {
+	return n.Kind == KindBond || n.Kind == KindBridge || n.Kind == KindVLAN
+}
+
+// ParentOf returns the bond or bridge that lists name as a member
+func ParentOf(networks map[string]NetworkConfig, name string) (string, bool) {
+	for parent, cfg := range networks {
+		for _, member := range cfg.Members {
+			if member == name {
+				return parent, true
+			}
+		}
+	}
+	return "", false
+}
+
+// OrderedNames returns the interface names so every device comes after the
+// members and VLAN links it depends on; ties are broken alphabetically
+func OrderedNames(networks map[string]NetworkConfig) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+
+	var visit func(name string)
+	visit = func(name string) {
+		cfg, ok := networks[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, member := range cfg.Members {
+			visit(member)
+		}
+		if cfg.Link != "" {
+			visit(cfg.Link)
+		}
+		ordered = append(ordered, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered
+}
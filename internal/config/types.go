@@ -1,52 +1,253 @@
 package config
 
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // ServerConfig holds the server configuration
 type ServerConfig struct {
-	Listen        string `yaml:"listen"`
-	InstancesFile string `yaml:"instances_file"`
+	Listen         string         `yaml:"listen"`
+	InstancesFile  string         `yaml:"instances_file"`
+	WatchInterval  string         `yaml:"watch_interval"`  // How often to check instances_file for changes, e.g. "2s"; "0" disables
+	LogFormat      string         `yaml:"log_format"`      // text (default) or json
+	TLSCert        string         `yaml:"tls_cert"`        // Server certificate (PEM); enables HTTPS
+	TLSKey         string         `yaml:"tls_key"`         // Server private key (PEM)
+	ClientCA       string         `yaml:"client_ca"`       // CA that client certificates must chain to (mTLS)
+	RequireToken   bool           `yaml:"require_token"`   // Reject instances without a user.cyber-range.token
+	AllowedSources []string       `yaml:"allowed_sources"` // CIDRs allowed on /config and /report; empty allows all
+	AdminSources   []string       `yaml:"admin_sources"`   // CIDRs allowed on /reload, /status, /instances and /metrics; empty means loopback only
+	StrictSource   bool           `yaml:"strict_source"`   // Source IP or its neighbour MAC must belong to the requested instance
+	ResponseKey    string         `yaml:"response_key"`    // Ed25519 private key (PKCS#8 PEM) for signing config responses
+	AdminToken     string         `yaml:"admin_token"`     // Bearer token for /reload, /status, /instances and /metrics
+	Hostname       HostnameConfig `yaml:"hostname"`
+	LXD            LXDConfig      `yaml:"lxd"`
+}
+
+// HostnameConfig holds the rules deriving guest hostnames from LXD instance names
+// An instance's user.hostname key, when set, is used as-is instead
+type HostnameConfig struct {
+	StripPrefix string `yaml:"strip_prefix"` // Literal prefix removed from instance names, e.g. "proj-"
+	StripRegex  string `yaml:"strip_regex"`  // Matches removed from instance names, e.g. "^[a-z]+-team[0-9]+-"
+	Case        string `yaml:"case"`         // upper, lower, or empty to keep the case
+	Domain      string `yaml:"domain"`       // DNS domain appended for the FQDN, e.g. "range.local"
+}
+
+// LXDConfig holds settings for querying the LXD REST API directly
+// When URL is empty the server reads InstancesFile instead
+type LXDConfig struct {
+	URL             string `yaml:"url"`              // e.g., https://lxd-server:8443
+	Cert            string `yaml:"cert"`             // Client certificate (PEM)
+	Key             string `yaml:"key"`              // Client private key (PEM)
+	CA              string `yaml:"ca"`               // Optional CA/server certificate to trust
+	Project         string `yaml:"project"`          // LXD project to query
+	RefreshInterval string `yaml:"refresh_interval"` // e.g., "30s"; empty or "0" reloads on demand
 }
 
 // ConfigResponse is sent from server to client
 type ConfigResponse struct {
-	Hostname string                   `json:"hostname"`
-	Network  NetworkConfig            `json:"network"`            // Primary network (backwards compat)
-	Networks map[string]NetworkConfig `json:"networks,omitempty"` // All networks keyed by interface name
+	Hostname   string                   `json:"hostname"`              // Short name
+	FQDN       string                   `json:"fqdn,omitempty"`        // Hostname with the DNS domain, when one is configured
+	MatchedMAC string                   `json:"matched_mac,omitempty"` // Which of the requested MACs identified the instance
+	Interface  string                   `json:"interface,omitempty"`   // Device name of the primary network (NIC that owns the requesting MAC)
+	Network    NetworkConfig            `json:"network"`               // Primary network (backwards compat)
+	Networks   map[string]NetworkConfig `json:"networks,omitempty"`    // All networks keyed by interface name
+	Metadata   map[string]string        `json:"metadata,omitempty"`    // user.cyber-range.* keys without the prefix (token excluded)
+	RequestID  string                   `json:"-"`                     // Filled by clients from the X-Request-ID header
+}
+
+// ClientReport is posted by clients to /report after each run
+type ClientReport struct {
+	Hostname   string             `json:"hostname"`
+	MAC        string             `json:"mac"`
+	Client     string             `json:"client"`               // windows, linux or openwrt
+	RequestID  string             `json:"request_id,omitempty"` // ID of the /config call the client applied
+	Success    bool               `json:"success"`              // True when the run finished without errors
+	Interfaces []string           `json:"interfaces,omitempty"` // Interfaces the client applied config to
+	Errors     []string           `json:"errors,omitempty"`
+	Timings    map[string]float64 `json:"timings,omitempty"` // Seconds per step (e.g., "fetch", "network", "total")
+	StartedAt  time.Time          `json:"started_at"`
+	ReceivedAt time.Time          `json:"received_at"` // Set by the server
 }
 
+// Network interface kinds
+const (
+	KindEthernet = "ethernet"
+	KindBond     = "bond"
+	KindBridge   = "bridge"
+	KindVLAN     = "vlan"
+)
+
 // NetworkConfig holds network configuration for the client
 type NetworkConfig struct {
-	DHCP    bool     `json:"dhcp"`
-	Address string   `json:"address,omitempty"` // CIDR format: 192.168.1.100/24
-	Gateway string   `json:"gateway,omitempty"`
-	DNS     []string `json:"dns,omitempty"`
-	Routes  []Route  `json:"routes,omitempty"` // Custom routes (non-default)
+	Kind       string            `json:"kind,omitempty"`       // ethernet (default), bond, bridge or vlan
+	Members    []string          `json:"members,omitempty"`    // Bond/bridge member interfaces (keys in Networks)
+	Link       string            `json:"link,omitempty"`       // VLAN parent interface (key in Networks)
+	VLANID     int               `json:"vlan_id,omitempty"`    // VLAN tag
+	Parameters map[string]string `json:"parameters,omitempty"` // Bond/bridge parameters using netplan names (mode, stp, ...)
+	MAC        string            `json:"mac,omitempty"`        // Hardware address of the NIC (from volatile.<dev>.hwaddr or match.macaddress)
+	SetName    string            `json:"set_name,omitempty"`   // Rename the NIC to this name (netplan set-name)
+	DHCP       bool              `json:"dhcp"`
+	DHCP6      bool              `json:"dhcp6,omitempty"`     // Stateful DHCPv6
+	AcceptRA   bool              `json:"accept_ra,omitempty"` // SLAAC from router advertisements
+	Address    string            `json:"address,omitempty"`   // First address, CIDR format: 192.168.1.100/24 (backwards compat)
+	Addresses  []string          `json:"addresses,omitempty"` // All addresses in CIDR format
+	Gateway    string            `json:"gateway,omitempty"`
+	Gateway6   string            `json:"gateway6,omitempty"`
+	Metric     int               `json:"metric,omitempty"` // Metric of the default route
+	MTU        int               `json:"mtu,omitempty"`
+	DNS        []string          `json:"dns,omitempty"`
+	Search     []string          `json:"search,omitempty"` // DNS search domains
+	Routes     []Route           `json:"routes,omitempty"` // Custom routes (non-default)
 }
 
-// LXDInstance represents an instance from lxc list --format json
+// LXDInstance represents an instance from lxc list --format json (or /1.0/instances?recursion=1)
+// The expanded fields have profiles applied; prefer them through ConfigValue,
+// EffectiveConfig and NICHwaddrs over reading Config directly
 type LXDInstance struct {
-	Name   string            `json:"name"`
-	Config map[string]string `json:"config"`
+	Name            string                       `json:"name"`
+	Type            string                       `json:"type,omitempty"`    // container or virtual-machine
+	Project         string                       `json:"project,omitempty"` // Empty for the default project in older exports
+	Status          string                       `json:"status,omitempty"`  // e.g. Running, Stopped
+	Profiles        []string                     `json:"profiles,omitempty"`
+	Config          map[string]string            `json:"config"`
+	ExpandedConfig  map[string]string            `json:"expanded_config,omitempty"`
+	ExpandedDevices map[string]map[string]string `json:"expanded_devices,omitempty"`
 }
 
 // CloudInitNetwork represents netplan-style network config
 type CloudInitNetwork struct {
 	Version   int                          `yaml:"version"`
 	Ethernets map[string]CloudInitEthernet `yaml:"ethernets"`
+	Bonds     map[string]CloudInitBond     `yaml:"bonds"`
+	Bridges   map[string]CloudInitBridge   `yaml:"bridges"`
+	VLANs     map[string]CloudInitVLAN     `yaml:"vlans"`
 }
 
-// CloudInitEthernet represents an ethernet device in cloud-init
+// CloudInitInterface holds the addressing keys shared by all netplan device types
+type CloudInitInterface struct {
+	DHCP4       bool                 `yaml:"dhcp4"`
+	DHCP6       bool                 `yaml:"dhcp6"`
+	AcceptRA    *bool                `yaml:"accept-ra"`
+	Addresses   []string             `yaml:"addresses"`
+	Gateway4    string               `yaml:"gateway4"`
+	Gateway6    string               `yaml:"gateway6"`
+	MTU         int                  `yaml:"mtu"`
+	Routes      []Route              `yaml:"routes"`
+	Nameservers CloudInitNameservers `yaml:"nameservers"`
+}
+
+// CloudInitEthernet represents an ethernet device in cloud-init (netplan v2)
 type CloudInitEthernet struct {
-	DHCP4       bool     `yaml:"dhcp4"`
-	Addresses   []string `yaml:"addresses"`
-	Gateway4    string   `yaml:"gateway4"`
-	Routes      []Route  `yaml:"routes"`
-	Nameservers struct {
-		Addresses []string `yaml:"addresses"`
-	} `yaml:"nameservers"`
+	Match              CloudInitMatch `yaml:"match"`
+	SetName            string         `yaml:"set-name"`
+	CloudInitInterface `yaml:",inline"`
+}
+
+// CloudInitBond represents a bond device; Interfaces are ethernet names
+type CloudInitBond struct {
+	Interfaces         []string               `yaml:"interfaces"`
+	Parameters         map[string]interface{} `yaml:"parameters"`
+	CloudInitInterface `yaml:",inline"`
+}
+
+// CloudInitBridge represents a bridge device; Interfaces are ethernet or bond names
+type CloudInitBridge struct {
+	Interfaces         []string               `yaml:"interfaces"`
+	Parameters         map[string]interface{} `yaml:"parameters"`
+	CloudInitInterface `yaml:",inline"`
+}
+
+// CloudInitVLAN represents an 802.1Q VLAN on top of Link
+type CloudInitVLAN struct {
+	ID                 int    `yaml:"id"`
+	Link               string `yaml:"link"`
+	CloudInitInterface `yaml:",inline"`
+}
+
+// CloudInitMatch selects a physical device by its properties
+type CloudInitMatch struct {
+	Name       string `yaml:"name"`
+	MACAddress string `yaml:"macaddress"`
+	Driver     string `yaml:"driver"`
+}
+
+// CloudInitNameservers holds DNS settings for a device
+type CloudInitNameservers struct {
+	Addresses []string `yaml:"addresses"`
+	Search    []string `yaml:"search"`
+}
+
+// CloudInitV1Network represents cloud-init network config version 1 (a list of entries)
+type CloudInitV1Network struct {
+	Version int                `yaml:"version"`
+	Config  []CloudInitV1Entry `yaml:"config"`
+}
+
+// CloudInitV1Entry is one v1 config entry: physical, bond, bridge, vlan, nameserver or route
+type CloudInitV1Entry struct {
+	Type             string                 `yaml:"type"`
+	Name             string                 `yaml:"name"`
+	MACAddress       string                 `yaml:"mac_address"`
+	MTU              int                    `yaml:"mtu"`
+	Subnets          []CloudInitV1Subnet    `yaml:"subnets"`
+	BondInterfaces   []string               `yaml:"bond_interfaces"`
+	BridgeInterfaces []string               `yaml:"bridge_interfaces"`
+	Params           map[string]interface{} `yaml:"params"`
+	VLANLink         string                 `yaml:"vlan_link"`
+	VLANID           int                    `yaml:"vlan_id"`
+
+	// type: nameserver
+	Address StringList `yaml:"address"`
+	Search  StringList `yaml:"search"`
+
+	// type: route
+	CloudInitV1Route `yaml:",inline"`
+}
+
+// CloudInitV1Subnet is one addressing method of a v1 interface
+type CloudInitV1Subnet struct {
+	Type           string             `yaml:"type"` // static, static6, dhcp, dhcp4, dhcp6, ipv6_slaac, ipv6_dhcpv6-stateful, ipv6_dhcpv6-stateless, manual
+	Address        string             `yaml:"address"`
+	Netmask        string             `yaml:"netmask"`
+	Gateway        string             `yaml:"gateway"`
+	DNSNameservers StringList         `yaml:"dns_nameservers"`
+	DNSSearch      StringList         `yaml:"dns_search"`
+	Routes         []CloudInitV1Route `yaml:"routes"`
+}
+
+// StringList is a YAML list of strings that may also be written as a single scalar,
+// as v1 configs do for nameserver addresses
+type StringList []string
+
+// UnmarshalYAML accepts either a scalar or a sequence of scalars
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// CloudInitV1Route is a v1 route, given as network and netmask or as destination
+type CloudInitV1Route struct {
+	Network     string `yaml:"network"`
+	Netmask     string `yaml:"netmask"`
+	Destination string `yaml:"destination"`
+	Gateway     string `yaml:"gateway"`
+	Metric      int    `yaml:"metric"`
 }
 
 // Route represents a network route
 type Route struct {
-	To  string `yaml:"to"`
-	Via string `yaml:"via"`
+	To     string `yaml:"to" json:"to"`
+	Via    string `yaml:"via" json:"via"`
+	Metric int    `yaml:"metric" json:"metric,omitempty"`
+	Table  int    `yaml:"table" json:"table,omitempty"`
 }
@@ -0,0 +1,287 @@
+package forge
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cyber-range-config/internal/signing"
+)
+
+// PKIDir is the directory inside the project holding the range CA and certificates
+const PKIDir = "pki"
+
+// File names inside PKIDir
+const (
+	CACertFile     = "ca.pem"
+	CAKeyFile      = "ca-key.pem"
+	ServerCertFile = "server.pem"
+	ServerKeyFile  = "server-key.pem"
+	ClientCertFile = "client.pem"
+	ClientKeyFile  = "client-key.pem"
+
+	ResponseKeyFile       = "response-key.pem" // Ed25519 key the server signs config responses with
+	ResponsePublicKeyFile = "response.pub"     // Base64 public key to build into the clients
+)
+
+// certValidity is how long generated certificates stay valid
+const certValidity = 365 * 24 * time.Hour
+
+// GenerateRangePKI creates the project's CA (reusing an existing one) and signs a
+// server certificate for hosts and a shared client certificate.
+// Existing server and client keys are reused, so re-running keeps the pin stable.
+// Returns the server public key pin for the clients' -pin flag.
+func GenerateRangePKI(workDir, projectName string, hosts []string) (string, error) {
+	dir := filepath.Join(workDir, PKIDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	serverTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: projectName + " config server"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+
+	serverCert, err := issueCertificate(dir, ServerCertFile, ServerKeyFile, serverTemplate, caCert, caKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to issue server certificate: %w", err)
+	}
+
+	clientTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: projectName + " client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if _, err := issueCertificate(dir, ClientCertFile, ClientKeyFile, clientTemplate, caCert, caKey); err != nil {
+		return "", fmt.Errorf("failed to issue client certificate: %w", err)
+	}
+
+	sum := sha256.Sum256(serverCert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HasServerCertificate reports whether the project has a generated server certificate
+func HasServerCertificate(workDir string) bool {
+	_, err := os.Stat(filepath.Join(workDir, PKIDir, ServerCertFile))
+	return err == nil
+}
+
+// GenerateResponseKey creates the project's response signing key (reusing an existing one)
+// Returns the base64 public key for the clients' -verify-key or built-in key
+func GenerateResponseKey(workDir string) (string, error) {
+	dir := filepath.Join(workDir, PKIDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	keyPath := filepath.Join(dir, ResponseKeyFile)
+	if key, err := signing.LoadPrivateKey(keyPath); err == nil {
+		return signing.EncodePublicKey(key.Public().(ed25519.PublicKey)), nil
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate response signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode response signing key: %w", err)
+	}
+	if err := writePEM(keyPath, "PRIVATE KEY", der, 0600); err != nil {
+		return "", err
+	}
+
+	encoded := signing.EncodePublicKey(public)
+	pubPath := filepath.Join(dir, ResponsePublicKeyFile)
+	if err := os.WriteFile(pubPath, []byte(encoded+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", pubPath, err)
+	}
+
+	return encoded, nil
+}
+
+// HasResponseKey reports whether the project has a response signing key
+func HasResponseKey(workDir string) bool {
+	_, err := os.Stat(filepath.Join(workDir, PKIDir, ResponseKeyFile))
+	return err == nil
+}
+
+// loadOrCreateCA reads the CA from dir, or creates and writes a new one
+func loadOrCreateCA(dir, projectName string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, CACertFile)
+	keyPath := filepath.Join(dir, CAKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		cert, key, err := parseCA(certPEM, keyPEM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load existing CA from %s: %w", dir, err)
+		}
+		return cert, key, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: projectName + " range CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(certValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode CA key: %w", err)
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	return cert, key, nil
+}
+
+// parseCA decodes a PEM CA certificate and EC private key
+func parseCA(certPEM, keyPEM []byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM key found")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// issueCertificate signs the key in keyFile (generating it if missing) with the CA
+// and writes the certificate to dir
+func issueCertificate(dir, certFile, keyFile string, template, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	key, err := loadOrCreateKey(filepath.Join(dir, keyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(certValidity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writePEM(filepath.Join(dir, certFile), "CERTIFICATE", der, 0644); err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// loadOrCreateKey reads the EC private key at path, or generates and writes a new one
+func loadOrCreateKey(path string) (*ecdsa.PrivateKey, error) {
+	if keyPEM, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM key found in %s", path)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load existing key %s: %w", path, err)
+		}
+		return key, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(path, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+// writePEM writes a single PEM block to path
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
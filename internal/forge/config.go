@@ -14,6 +14,10 @@ import (
 type ForgeConfig struct {
 	Listen      string `yaml:"listen"`       // e.g., "10.8.11.202:8080"
 	IdleTimeout string `yaml:"idle_timeout"` // e.g., "5m"
+	Deadline    string `yaml:"deadline"`     // e.g., "2h"; shut down once every instance checked in
+
+	RequireClientCert bool `yaml:"require_client_cert"` // Enforce mTLS when the project has a pki/ directory
+	BootstrapTokens   bool `yaml:"bootstrap_tokens"`    // Generate per-instance tokens and require them
 }
 
 // ServerBinary is the path to the server binary
@@ -2,10 +2,13 @@ package forge
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -16,7 +19,13 @@ type DeployConfig struct {
 	ServerIP       string
 	InstancesFile  string
 	IdleTimeout    string
+	Deadline       string // When set, replaces the idle timeout with completion-aware shutdown
 	StartWinScript string
+
+	TLS               bool // Serve HTTPS with the certificates in the project's pki/ directory
+	RequireClientCert bool // Require client certificates signed by the range CA
+	BootstrapTokens   bool // Generate user.cyber-range.token per instance and require signed requests
+	SignResponses     bool // Sign config responses with the key in the project's pki/ directory
 }
 
 // DefaultDeployConfig returns deployment configuration, reading from config.yaml if available
@@ -53,6 +62,13 @@ func DefaultDeployConfig() DeployConfig {
 		config.IdleTimeout = cfg.IdleTimeout
 	}
 
+	if cfg.Deadline != "" {
+		config.Deadline = cfg.Deadline
+	}
+
+	config.RequireClientCert = cfg.RequireClientCert
+	config.BootstrapTokens = cfg.BootstrapTokens
+
 	return config
 }
 
@@ -89,7 +105,27 @@ func ExportInstances(workDir, projectName, instancesFile string) error {
 	// Count instances
 	var instances []interface{}
 	if err := json.Unmarshal(output, &instances); err == nil {
-		fmt.Printf("\033[32m[INFO]\033[0m Exported %d instances to %s\n", len(instances), instancesFile)
+		slog.Info("Exported instances", "count", len(instances), "file", instancesFile)
+	}
+
+	return nil
+}
+
+// CheckInstances validates the exported instance configs with the server's -check mode
+func CheckInstances(workDir string, config DeployConfig) error {
+	instancesPath := filepath.Join(workDir, config.InstancesFile)
+	cmd := exec.Command(config.ServerBinary, "-check", "-instances", instancesPath)
+	cmd.Dir = workDir
+
+	// Problems are printed on stdout; stderr only matters if the file could not be loaded
+	output, err := cmd.Output()
+	fmt.Print(string(output))
+	if err != nil {
+		var exitErr *exec.ExitError
+		if len(output) == 0 && errors.As(err, &exitErr) {
+			return fmt.Errorf("instance config check failed: %s - %w", strings.TrimSpace(string(exitErr.Stderr)), err)
+		}
+		return fmt.Errorf("instance config check failed: %w", err)
 	}
 
 	return nil
@@ -109,11 +145,31 @@ func StartServer(workDir string, config DeployConfig) error {
 	listenAddr := fmt.Sprintf("%s:%s", config.ServerIP, config.ServerPort)
 
 	// Start server in background
-	cmd := exec.Command(config.ServerBinary,
+	args := []string{
 		"-listen", listenAddr,
 		"-instances", instancesPath,
 		"-idle-timeout", config.IdleTimeout,
-	)
+	}
+	if config.Deadline != "" {
+		args = append(args, "-deadline", config.Deadline)
+	}
+	if config.BootstrapTokens {
+		args = append(args, "-require-token")
+	}
+	if config.TLS {
+		pkiDir := filepath.Join(workDir, PKIDir)
+		args = append(args,
+			"-tls-cert", filepath.Join(pkiDir, ServerCertFile),
+			"-tls-key", filepath.Join(pkiDir, ServerKeyFile),
+		)
+		if config.RequireClientCert {
+			args = append(args, "-client-ca", filepath.Join(pkiDir, CACertFile))
+		}
+	}
+	if config.SignResponses {
+		args = append(args, "-response-key", filepath.Join(workDir, PKIDir, ResponseKeyFile))
+	}
+	cmd := exec.Command(config.ServerBinary, args...)
 	cmd.Dir = workDir
 
 	// Redirect output to log file
@@ -135,15 +191,22 @@ func StartServer(workDir string, config DeployConfig) error {
 	if cmd.Process != nil {
 		// Check if process is still running
 		if err := cmd.Process.Signal(os.Signal(nil)); err == nil {
-			fmt.Printf("\033[32m[INFO]\033[0m Server started (PID: %d)\n", cmd.Process.Pid)
-			fmt.Printf("\033[32m[INFO]\033[0m Server listening on %s\n", listenAddr)
-			fmt.Printf("\033[32m[INFO]\033[0m Server will auto-shutdown after %s of inactivity\n", config.IdleTimeout)
+			slog.Info("Server started", "pid", cmd.Process.Pid, "listen", listenAddr)
+			slog.Info(shutdownDescription(config))
 		}
 	}
 
 	return nil
 }
 
+// shutdownDescription explains when the server will shut itself down
+func shutdownDescription(config DeployConfig) string {
+	if config.Deadline != "" {
+		return fmt.Sprintf("Server will auto-shutdown once every instance has checked in, or after %s", config.Deadline)
+	}
+	return fmt.Sprintf("Server will auto-shutdown after %s of inactivity", config.IdleTimeout)
+}
+
 // StopServer stops the config server
 func StopServer() {
 	// Use pkill to find and kill server processes
@@ -173,25 +236,42 @@ func RunPostApply(workDir, projectName string, config DeployConfig) error {
 	fmt.Println()
 
 	// Wait for VMs
-	fmt.Printf("\033[32m[INFO]\033[0m Waiting for VMs to initialize...\n")
+	slog.Info("Waiting for VMs to initialize...")
 	WaitForVMs(10)
 
+	// Generate bootstrap tokens before export so the server sees them
+	if config.BootstrapTokens {
+		slog.Info("Generating bootstrap tokens...")
+		count, err := EnsureBootstrapTokens(workDir, projectName)
+		if err != nil {
+			slog.Warn(err.Error())
+		} else {
+			slog.Info("Bootstrap tokens ready", "generated", count)
+		}
+	}
+
 	// Export instances
-	fmt.Printf("\033[32m[INFO]\033[0m Exporting LXD instances...\n")
+	slog.Info("Exporting LXD instances...")
 	if err := ExportInstances(workDir, projectName, config.InstancesFile); err != nil {
-		fmt.Printf("\033[33m[WARN]\033[0m %s\n", err.Error())
+		slog.Warn(err.Error())
+	}
+
+	// Catch config mistakes before any VM asks for its config
+	slog.Info("Checking instance configs...")
+	if err := CheckInstances(workDir, config); err != nil {
+		slog.Warn(err.Error())
 	}
 
 	// Start server
-	fmt.Printf("\033[32m[INFO]\033[0m Starting config server...\n")
+	slog.Info("Starting config server...")
 	if err := StartServer(workDir, config); err != nil {
-		fmt.Printf("\033[33m[WARN]\033[0m %s\n", err.Error())
+		slog.Warn(err.Error())
 	}
 
 	// Start Windows VMs
-	fmt.Printf("\033[32m[INFO]\033[0m Starting Windows VMs...\n")
+	slog.Info("Starting Windows VMs...")
 	if err := StartWindowsVMs(projectName, config.StartWinScript); err != nil {
-		fmt.Printf("\033[33m[WARN]\033[0m %s\n", err.Error())
+		slog.Warn(err.Error())
 	}
 
 	return nil
@@ -199,7 +279,7 @@ func RunPostApply(workDir, projectName string, config DeployConfig) error {
 
 // RunPreDestroy runs all pre-destroy steps
 func RunPreDestroy() {
-	fmt.Printf("\033[32m[INFO]\033[0m Stopping config server...\n")
+	slog.Info("Stopping config server...")
 	StopServer()
 }
 
@@ -210,14 +290,19 @@ func PrintDeploymentComplete(config DeployConfig, subnetOctet int) {
 	fmt.Println("  Deployment Complete!")
 	fmt.Println("==========================================")
 	fmt.Println()
-	fmt.Printf("Server running at: http://%s:%s\n", config.ServerIP, config.ServerPort)
+	scheme := "http"
+	if config.TLS {
+		scheme = "https"
+	}
+	fmt.Printf("Server running at: %s://%s:%s\n", scheme, config.ServerIP, config.ServerPort)
 	fmt.Printf("Guac subnet: 10.0.%d.0/24 (gateway: 10.0.%d.1)\n", subnetOctet, subnetOctet)
-	fmt.Printf("Idle timeout: %s (server will auto-shutdown after no requests)\n", config.IdleTimeout)
+	fmt.Printf("Shutdown: %s\n", shutdownDescription(config))
 	fmt.Println()
 	fmt.Println("Endpoints:")
 	fmt.Println("  GET  /config?mac=XX:XX:XX:XX:XX:XX  - Get VM config")
 	fmt.Println("  POST /reload                         - Reload instances.json")
-	fmt.Println("  GET  /status                         - Check server status")
+	fmt.Println("  GET  /status                         - Check server status and check-ins")
+	fmt.Println("  POST /report                         - Client check-in")
 	fmt.Println()
 	fmt.Println("Windows VMs will automatically configure themselves on boot.")
 }
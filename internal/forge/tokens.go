@@ -0,0 +1,106 @@
+package forge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/token"
+)
+
+// EnsureBootstrapTokens gives every instance in the project without a bootstrap
+// token a random one, stored in its LXD config where the guest can read it
+// Windows VMs have no /dev/lxd/sock, so their token is also passed in as an SMBIOS
+// OEM string through raw.qemu, which takes effect on their next start
+// Returns the number of tokens generated
+func EnsureBootstrapTokens(workDir, projectName string) (int, error) {
+	listCmd := exec.Command("lxc", "list", "--project", projectName, "--format", "json")
+	listCmd.Dir = workDir
+	output, err := listCmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var instances []config.LXDInstance
+	if err := json.Unmarshal(output, &instances); err != nil {
+		return 0, fmt.Errorf("failed to parse instance list: %w", err)
+	}
+
+	generated := 0
+	for i := range instances {
+		instance := &instances[i]
+
+		secret := instance.Config[token.ConfigKey]
+		if secret == "" {
+			if secret, err = randomToken(); err != nil {
+				return generated, err
+			}
+			if err := setInstanceConfig(workDir, projectName, instance.Name, token.ConfigKey, secret); err != nil {
+				return generated, err
+			}
+			generated++
+		}
+
+		if instance.IsWindows() {
+			if err := deliverWindowsToken(workDir, projectName, instance, secret); err != nil {
+				return generated, err
+			}
+		}
+	}
+
+	return generated, nil
+}
+
+// smbiosTokenArg matches a bootstrap token OEM string previously added to raw.qemu
+var smbiosTokenArg = regexp.MustCompile(`\s*-smbios type=11,value=` + regexp.QuoteMeta(token.SMBIOSPrefix) + `\S*`)
+
+// deliverWindowsToken passes secret to a Windows VM as an SMBIOS OEM string, keeping
+// any other raw.qemu arguments (including ones from profiles)
+func deliverWindowsToken(workDir, projectName string, instance *config.LXDInstance, secret string) error {
+	// Only VMs take raw.qemu; the server keeps refusing an instance that cannot sign
+	if instance.Type != "virtual-machine" {
+		slog.Warn("Windows instance is not a VM; it cannot receive its bootstrap token", "instance", instance.Name)
+		return nil
+	}
+
+	arg := "-smbios type=11,value=" + token.SMBIOSPrefix + secret
+	current := instance.ConfigValue("raw.qemu")
+	if strings.Contains(current, arg) {
+		return nil
+	}
+
+	rawQEMU := strings.TrimSpace(smbiosTokenArg.ReplaceAllString(current, "") + " " + arg)
+	if err := setInstanceConfig(workDir, projectName, instance.Name, "raw.qemu", rawQEMU); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(instance.Status, "running") {
+		slog.Warn("Windows VM is running; it sees its bootstrap token after a restart", "instance", instance.Name)
+	}
+	return nil
+}
+
+// setInstanceConfig sets one LXD config key on an instance
+func setInstanceConfig(workDir, projectName, name, key, value string) error {
+	setCmd := exec.Command("lxc", "config", "set", name, key+"="+value, "--project", projectName)
+	setCmd.Dir = workDir
+	if output, err := setCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s on %s: %s - %w", key, name, string(output), err)
+	}
+	return nil
+}
+
+// randomToken returns a random 256-bit hex secret
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os/exec"
+	"strings"
+
+	"cyber-range-config/internal/config"
+)
+
+// defaultAdminSources limits the admin endpoints to the server host unless configured
+var defaultAdminSources = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}
+
+// ParsePrefixes parses CIDRs (or bare IPs, taken as single hosts) for an allow-list
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SetAllowedSources limits the client endpoints (/config, /report) to the given networks
+// An empty list allows every source
+func (s *Server) SetAllowedSources(prefixes []netip.Prefix) {
+	s.allowedSources = prefixes
+}
+
+// SetAdminSources limits the admin endpoints (/reload, /status, /instances, /metrics) to the given networks
+// An empty list keeps the default of loopback only
+func (s *Server) SetAdminSources(prefixes []netip.Prefix) {
+	if len(prefixes) == 0 {
+		prefixes = defaultAdminSources
+	}
+	s.adminSources = prefixes
+}
+
+// SetAdminToken requires "Authorization: Bearer <token>" on the admin endpoints
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetStrictSource requires /config requests to come from the instance they ask for
+func (s *Server) SetStrictSource(enabled bool) {
+	s.strictSource = enabled
+}
+
+// allowClients wraps a client endpoint with the listener's allow-list
+func (s *Server) allowClients(next http.HandlerFunc) http.HandlerFunc {
+	return allowFrom(func() []netip.Prefix { return s.allowedSources }, next)
+}
+
+// allowAdmins wraps an admin endpoint with the admin allow-list and token
+func (s *Server) allowAdmins(next http.HandlerFunc) http.HandlerFunc {
+	return allowFrom(func() []netip.Prefix { return s.adminSources }, func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken != "" && !validBearer(r, s.adminToken) {
+			slog.Warn("Rejected admin request: bad or missing token", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// allowAdminSources wraps a page with the admin allow-list but not the token
+// Used for the dashboard, which holds no data itself and sends the token on its API calls
+func (s *Server) allowAdminSources(next http.HandlerFunc) http.HandlerFunc {
+	return allowFrom(func() []netip.Prefix { return s.adminSources }, next)
+}
+
+// validBearer reports whether the request carries the expected bearer token
+func validBearer(r *http.Request, want string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// allowFrom rejects requests whose source address is outside the allow-list
+func allowFrom(prefixes func() []netip.Prefix, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := prefixes()
+		if len(allowed) == 0 {
+			next(w, r)
+			return
+		}
+
+		addr, err := remoteAddr(r)
+		if err != nil || !containsAddr(allowed, addr) {
+			slog.Warn("Rejected request from disallowed source", "remote", r.RemoteAddr, "path", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// remoteAddr returns the request's source IP without port or IPv4-in-IPv6 mapping
+func remoteAddr(r *http.Request) (netip.Addr, error) {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q: %w", r.RemoteAddr, err)
+	}
+	return addrPort.Addr().Unmap(), nil
+}
+
+// containsAddr reports whether any prefix contains addr
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSource verifies that a config request plausibly comes from the instance it asks for:
+// the source is one of the instance's static addresses, or the neighbour table maps
+// it to one of the instance's MACs (which needs the client on the server's L2 segment)
+func checkSource(instance *config.LXDInstance, networks map[string]config.NetworkConfig, r *http.Request) error {
+	addr, err := remoteAddr(r)
+	if err != nil {
+		return err
+	}
+
+	for _, netCfg := range networks {
+		for _, cidr := range netCfg.AllAddresses() {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err == nil && prefix.Addr().Unmap() == addr {
+				return nil
+			}
+		}
+	}
+
+	neighbour, err := neighbourMAC(addr)
+	if err != nil {
+		return err
+	}
+	if neighbour == "" {
+		return fmt.Errorf("%s is not an address of the instance and has no neighbour entry", addr)
+	}
+	if !instanceHasMAC(instance, networks, neighbour) {
+		return fmt.Errorf("%s belongs to %s, which is not a MAC of the instance", addr, neighbour)
+	}
+	return nil
+}
+
+// instanceHasMAC reports whether mac is one of the instance's NIC addresses
+func instanceHasMAC(instance *config.LXDInstance, networks map[string]config.NetworkConfig, mac string) bool {
+	for _, hwaddr := range instance.NICHwaddrs() {
+		if strings.EqualFold(hwaddr, mac) {
+			return true
+		}
+	}
+	for _, netCfg := range networks {
+		if netCfg.MAC != "" && strings.EqualFold(netCfg.MAC, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// neighbourMAC looks up the link-layer address of addr in the kernel neighbour table
+// Returns an empty string when there is no entry
+func neighbourMAC(addr netip.Addr) (string, error) {
+	cmd := exec.Command("ip", "neigh", "show", "to", addr.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read neighbour table: %s - %w", string(output), err)
+	}
+
+	fields := strings.Fields(string(output))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "lladdr" {
+			return normalizeMAC(fields[i+1]), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []string
+		wantErr string
+	}{
+		{name: "empty", values: nil, want: nil},
+		{name: "CIDR masked", values: []string{"10.0.14.7/24"}, want: []string{"10.0.14.0/24"}},
+		{name: "bare IPv4 is one host", values: []string{"10.8.11.5"}, want: []string{"10.8.11.5/32"}},
+		{name: "bare IPv6 is one host", values: []string{"fd00::1"}, want: []string{"fd00::1/128"}},
+		{name: "blanks and spaces skipped", values: []string{" 10.0.0.0/8 ", "", " "}, want: []string{"10.0.0.0/8"}},
+		{name: "bad address", values: []string{"10.0.0.300"}, wantErr: "invalid address"},
+		{name: "bad CIDR", values: []string{"10.0.0.0/33"}, wantErr: "invalid CIDR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParsePrefixes(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowClients(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		remote  string
+		want    int
+	}{
+		{name: "no allow-list", remote: "192.0.2.1:5000", want: http.StatusOK},
+		{name: "inside", sources: []string{"10.0.14.0/24"}, remote: "10.0.14.20:5000", want: http.StatusOK},
+		{name: "IPv4-mapped inside", sources: []string{"10.0.14.0/24"}, remote: "[::ffff:10.0.14.20]:5000", want: http.StatusOK},
+		{name: "outside", sources: []string{"10.0.14.0/24"}, remote: "10.0.15.20:5000", want: http.StatusForbidden},
+		{name: "unparsable remote", sources: []string{"10.0.14.0/24"}, remote: "pipe", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			s.SetAllowedSources(mustParsePrefixes(t, tt.sources))
+
+			if got := serveAccess(s.allowClients, tt.remote, ""); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowAdmins(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		remote  string
+		want    int
+	}{
+		{name: "loopback by default", remote: "127.0.0.1:5000", want: http.StatusOK},
+		{name: "IPv6 loopback by default", remote: "[::1]:5000", want: http.StatusOK},
+		{name: "remote refused by default", remote: "10.8.11.5:5000", want: http.StatusForbidden},
+		{name: "configured source", sources: []string{"10.8.11.0/24"}, remote: "10.8.11.5:5000", want: http.StatusOK},
+		{name: "configured source replaces loopback", sources: []string{"10.8.11.0/24"}, remote: "127.0.0.1:5000", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			s.SetAdminSources(mustParsePrefixes(t, tt.sources))
+
+			if got := serveAccess(s.allowAdmins, tt.remote, ""); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		remote        string
+		authorization string
+		want          int
+	}{
+		{name: "no token configured", remote: "127.0.0.1:5000", want: http.StatusOK},
+		{name: "token required", token: "t0ken", remote: "127.0.0.1:5000", want: http.StatusUnauthorized},
+		{name: "token accepted", token: "t0ken", remote: "127.0.0.1:5000", authorization: "Bearer t0ken", want: http.StatusOK},
+		{name: "wrong token", token: "t0ken", remote: "127.0.0.1:5000", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "t0ken", remote: "127.0.0.1:5000", authorization: "Basic t0ken", want: http.StatusUnauthorized},
+		{name: "token does not bypass sources", token: "t0ken", remote: "10.8.11.5:5000", authorization: "Bearer t0ken", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			s.SetAdminSources(nil)
+			s.SetAdminToken(tt.token)
+
+			if got := serveAccess(s.allowAdmins, tt.remote, tt.authorization); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// serveAccess runs a request from remote through an access wrapper and returns the status code
+func serveAccess(wrap func(http.HandlerFunc) http.HandlerFunc, remote, authorization string) int {
+	handler := wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.RemoteAddr = remote
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code
+}
+
+// mustParsePrefixes parses an allow-list or fails the test
+func mustParsePrefixes(t *testing.T, values []string) []netip.Prefix {
+	t.Helper()
+	prefixes, err := ParsePrefixes(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prefixes
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/token"
+)
+
+// Check-in states reported by the admin API
+const (
+	CheckInNone     = "none"     // Never requested config
+	CheckInFetched  = "fetched"  // Fetched config but has not reported
+	CheckInReported = "reported" // Posted a report
+)
+
+// redacted replaces secret config values in admin output
+const redacted = "[redacted]"
+
+// InstanceSummary describes an instance in GET /instances
+type InstanceSummary struct {
+	Name      string                          `json:"name"`
+	Hostname  string                          `json:"hostname"`           // As sent by /config
+	FQDN      string                          `json:"fqdn,omitempty"`     // As sent by /config
+	Type      string                          `json:"type,omitempty"`     // container or virtual-machine
+	Status    string                          `json:"status,omitempty"`   // LXD status at the last load
+	Profiles  []string                        `json:"profiles,omitempty"` // LXD profiles applied to the instance
+	MACs      map[string]string               `json:"macs"`               // LXD device name -> MAC
+	Networks  map[string]config.NetworkConfig `json:"networks"`           // As sent by /config
+	CheckIn   string                          `json:"check_in"`           // none, fetched or reported
+	FetchedAt *time.Time                      `json:"fetched_at,omitempty"`
+	Report    *config.ClientReport            `json:"report,omitempty"`
+
+	// MACs this instance shares with other instances, with their names; /config answers 409 for these
+	MACCollisions map[string][]string `json:"mac_collisions,omitempty"`
+}
+
+// HandleInstances handles GET /instances to list every instance with its networks and check-in state
+func (s *Server) HandleInstances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instances := s.snapshot().instances
+
+	summaries := make([]InstanceSummary, 0, len(instances))
+	for i := range instances {
+		summaries = append(summaries, s.summarizeInstance(&instances[i]))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+	writeJSON(w, summaries)
+}
+
+// HandleInstance handles GET /instances/{name} (the /config response for that instance)
+// and GET /instances/{name}/raw (its effective LXD config keys, profiles applied)
+// An optional ?mac= picks the NIC the response is built for, and ?client= the client type, as /config would
+func (s *Server) HandleInstance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, raw := strings.TrimPrefix(r.URL.Path, "/instances/"), false
+	if strings.HasSuffix(name, "/raw") {
+		name, raw = strings.TrimSuffix(name, "/raw"), true
+	}
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	instance := s.snapshot().lookupName(name)
+	if instance == nil {
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	if raw {
+		writeJSON(w, redactConfig(instance.EffectiveConfig()))
+		return
+	}
+
+	macs := instanceMACs(instance)
+	mac, device := normalizeMAC(r.URL.Query().Get("mac")), ""
+	if mac != "" {
+		for dev, devMAC := range macs {
+			if devMAC == mac {
+				device = dev
+			}
+		}
+		if device == "" {
+			http.Error(w, "MAC does not belong to instance", http.StatusNotFound)
+			return
+		}
+	} else if devices := sortedKeys(macs); len(devices) > 0 {
+		device = devices[0]
+		mac = macs[device]
+	}
+
+	windows := instance.IsWindows() || r.URL.Query().Get("client") == "windows"
+	writeJSON(w, s.buildConfigResponse(instance, s.parseAllNetworkConfigs(instance), mac, device, windows))
+}
+
+// summarizeInstance builds the admin listing entry for an instance
+func (s *Server) summarizeInstance(instance *config.LXDInstance) InstanceSummary {
+	hostname, fqdn := s.servedHostname(instance, instance.IsWindows())
+	summary := InstanceSummary{
+		Name:     instance.Name,
+		Hostname: hostname,
+		FQDN:     fqdn,
+		Type:     instance.Type,
+		Status:   instance.Status,
+		Profiles: instance.Profiles,
+		MACs:     instanceMACs(instance),
+		Networks: s.parseAllNetworkConfigs(instance),
+		CheckIn:  CheckInNone,
+
+		MACCollisions: s.instanceCollisions(instance.Name),
+	}
+
+	s.reportsMu.RLock()
+	defer s.reportsMu.RUnlock()
+
+	if fetched, ok := s.fetched[instance.Name]; ok {
+		summary.CheckIn = CheckInFetched
+		summary.FetchedAt = &fetched
+	}
+	if report, ok := s.reports[instance.Name]; ok {
+		summary.CheckIn = CheckInReported
+		summary.Report = &report
+	}
+
+	return summary
+}
+
+// instanceMACs returns the instance's MACs keyed by LXD device name
+func instanceMACs(instance *config.LXDInstance) map[string]string {
+	macs := instance.NICHwaddrs()
+	for device, mac := range macs {
+		macs[device] = normalizeMAC(mac)
+	}
+	return macs
+}
+
+// redactConfig copies instance config keys with secrets replaced
+func redactConfig(cfg map[string]string) map[string]string {
+	out := make(map[string]string, len(cfg))
+	for key, value := range cfg {
+		if key == token.ConfigKey {
+			value = redacted
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a string map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
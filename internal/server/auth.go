@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/token"
+)
+
+// SetRequireToken rejects config requests for instances that have no bootstrap token
+func (s *Server) SetRequireToken(required bool) {
+	s.requireToken = required
+}
+
+// SetResponseKey signs every config response with the given Ed25519 key
+func (s *Server) SetResponseKey(key ed25519.PrivateKey) {
+	s.responseKey = key
+}
+
+// verifyToken checks the request signature against the instance's bootstrap secret
+// Instances without a secret are served unsigned unless tokens are required
+func (s *Server) verifyToken(instance *config.LXDInstance, macs []string, r *http.Request) error {
+	secret, err := s.instanceSecret(instance)
+	if err != nil || secret == "" {
+		return err
+	}
+
+	return token.Verify(secret, macs, r.Header.Get(token.TimestampHeader), r.Header.Get(token.SignatureHeader), time.Now())
+}
+
+// verifiedForAny reports whether the request is signed with the token of one of
+// the named instances; instances without a token never count
+func (s *Server) verifiedForAny(snap *snapshot, names []string, r *http.Request) bool {
+	sentMACs := r.URL.Query()["mac"]
+	for _, name := range names {
+		instance := snap.lookupName(name)
+		if instance == nil || instance.ConfigValue(token.ConfigKey) == "" {
+			continue
+		}
+		if s.verifyToken(instance, sentMACs, r) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyReport checks a /report signature, which also covers the posted body
+func (s *Server) verifyReport(instance *config.LXDInstance, mac string, body []byte, r *http.Request) error {
+	secret, err := s.instanceSecret(instance)
+	if err != nil || secret == "" {
+		return err
+	}
+
+	return token.VerifyBody(secret, []string{mac}, r.Header.Get(token.TimestampHeader), r.Header.Get(token.SignatureHeader), body, time.Now())
+}
+
+// instanceSecret returns the instance's bootstrap secret, or "" if it may be served unsigned
+func (s *Server) instanceSecret(instance *config.LXDInstance) (string, error) {
+	secret := instance.ConfigValue(token.ConfigKey)
+	if secret == "" && s.requireToken {
+		return "", fmt.Errorf("instance has no %s", token.ConfigKey)
+	}
+	return secret, nil
+}
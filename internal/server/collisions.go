@@ -0,0 +1,60 @@
+package server
+
+import (
+	"sort"
+
+	"cyber-range-config/internal/config"
+)
+
+// findMACCollisions maps every MAC used by more than one instance to the sorted instance names
+func findMACCollisions(instances []config.LXDInstance) map[string][]string {
+	owners := make(map[string][]string)
+	for i := range instances {
+		for _, mac := range instanceMACs(&instances[i]) {
+			names := owners[mac]
+			if len(names) > 0 && names[len(names)-1] == instances[i].Name {
+				continue // Same MAC on two devices of one instance
+			}
+			owners[mac] = append(names, instances[i].Name)
+		}
+	}
+
+	collisions := make(map[string][]string)
+	for mac, names := range owners {
+		if len(names) > 1 {
+			sort.Strings(names)
+			collisions[mac] = names
+		}
+	}
+	return collisions
+}
+
+// MACCollisions returns the MACs shared by several instances, with the instance names
+func (s *Server) MACCollisions() map[string][]string {
+	current := s.snapshot().collisions
+	collisions := make(map[string][]string, len(current))
+	for mac, names := range current {
+		collisions[mac] = names
+	}
+	return collisions
+}
+
+// instanceCollisions returns the colliding MACs of one instance with the other instances using them
+func (s *Server) instanceCollisions(name string) map[string][]string {
+	var collisions map[string][]string
+	for mac, names := range s.snapshot().collisions {
+		var others []string
+		for _, other := range names {
+			if other != name {
+				others = append(others, other)
+			}
+		}
+		if len(others) < len(names) {
+			if collisions == nil {
+				collisions = make(map[string][]string)
+			}
+			collisions[mac] = others
+		}
+	}
+	return collisions
+}
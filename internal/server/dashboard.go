@@ -0,0 +1,33 @@
+package server
+
+import (
+	"embed"
+	"net/http"
+)
+
+// dashboardFS holds the bring-up dashboard; it loads its data from /instances and /status
+//
+//go:embed dashboard/index.html
+var dashboardFS embed.FS
+
+// HandleDashboard handles GET / with the embedded HTML dashboard
+func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := dashboardFS.ReadFile("dashboard/index.html")
+	if err != nil {
+		http.Error(w, "Dashboard unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(page)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cyber-range-config/internal/config"
+)
+
+// HostnameKey is the instance config key that overrides the derived hostname
+const HostnameKey = "user.hostname"
+
+// HostnameRules derive guest hostnames from LXD instance names
+type HostnameRules struct {
+	stripPrefix string
+	stripRegex  *regexp.Regexp
+	upper       bool
+	lower       bool
+	domain      string
+}
+
+// NewHostnameRules checks and compiles the hostname rules from the server config
+func NewHostnameRules(cfg config.HostnameConfig) (*HostnameRules, error) {
+	rules := &HostnameRules{
+		stripPrefix: cfg.StripPrefix,
+		domain:      strings.ToLower(strings.Trim(cfg.Domain, ".")),
+	}
+
+	if cfg.StripRegex != "" {
+		re, err := regexp.Compile(cfg.StripRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid strip_regex: %w", err)
+		}
+		rules.stripRegex = re
+	}
+
+	switch strings.ToLower(cfg.Case) {
+	case "":
+	case "upper":
+		rules.upper = true
+	case "lower":
+		rules.lower = true
+	default:
+		return nil, fmt.Errorf("invalid case %q (want upper or lower)", cfg.Case)
+	}
+
+	if rules.domain != "" {
+		if err := config.ValidateFQDN(rules.domain); err != nil {
+			return nil, fmt.Errorf("invalid domain: %w", err)
+		}
+	}
+
+	return rules, nil
+}
+
+// Hostname returns the short name and FQDN for an instance
+// user.hostname is used as-is (an FQDN there supplies its own domain); otherwise
+// the instance name is stripped and case-transformed. The FQDN is empty without a domain
+func (h *HostnameRules) Hostname(instance *config.LXDInstance) (string, string) {
+	if name := instance.ConfigValue(HostnameKey); name != "" {
+		if short, domain, ok := strings.Cut(name, "."); ok {
+			return short, short + "." + domain
+		}
+		return name, h.fqdn(name)
+	}
+
+	if h == nil {
+		return instance.Name, ""
+	}
+
+	name := strings.TrimPrefix(instance.Name, h.stripPrefix)
+	if h.stripRegex != nil {
+		name = h.stripRegex.ReplaceAllString(name, "")
+	}
+	if h.upper {
+		name = strings.ToUpper(name)
+	}
+	if h.lower {
+		name = strings.ToLower(name)
+	}
+	return name, h.fqdn(name)
+}
+
+// fqdn appends the configured domain to a short name
+func (h *HostnameRules) fqdn(short string) string {
+	if h == nil || h.domain == "" {
+		return ""
+	}
+	return short + "." + h.domain
+}
+
+// SetHostnameRules sets how hostnames are derived and revalidates the loaded instances
+func (s *Server) SetHostnameRules(rules *HostnameRules) {
+	s.hostnameRules = rules
+
+	snap := s.snapshot()
+	issues := s.Validate(snap.instances)
+	s.state.Store(newSnapshot(snap.instances, issues))
+
+	// The load already logged the rest
+	known := make(map[Issue]bool, len(snap.issues))
+	for _, issue := range snap.issues {
+		known[issue] = true
+	}
+	var added []Issue
+	for _, issue := range issues {
+		if !known[issue] {
+			added = append(added, issue)
+		}
+	}
+	logIssues(added)
+}
+
+// servedHostname returns the hostname and FQDN sent to an instance, made valid so
+// clients never get a name they would refuse: invalid characters become hyphens and
+// the name is cut to 63 characters, or 15 for Windows guests. An FQDN that is still
+// invalid is dropped
+func (s *Server) servedHostname(instance *config.LXDInstance, windows bool) (string, string) {
+	short, fqdn := s.hostnameRules.Hostname(instance)
+
+	maxLength := config.MaxHostnameLength
+	if windows {
+		maxLength = config.MaxNetBIOSLength
+	}
+	served := config.SanitizeHostname(short, maxLength)
+	if served == "" {
+		served = config.SanitizeHostname(instance.Name, maxLength)
+	}
+
+	if fqdn == "" {
+		return served, ""
+	}
+	labels := strings.Split(fqdn, ".")
+	labels[0] = served
+	for i := 1; i < len(labels); i++ {
+		labels[i] = config.SanitizeHostname(labels[i], config.MaxHostnameLength)
+	}
+	servedFQDN := strings.Join(labels, ".")
+	if config.ValidateFQDN(servedFQDN) != nil {
+		return served, ""
+	}
+	return served, servedFQDN
+}
+
+// checkHostname describes what is wrong with an instance's hostname, or returns ""
+// Windows instances are held to the 15 character NetBIOS limit
+func (s *Server) checkHostname(instance *config.LXDInstance) string {
+	short, fqdn := s.hostnameRules.Hostname(instance)
+	served, servedFQDN := s.servedHostname(instance, instance.IsWindows())
+
+	maxLength := config.MaxHostnameLength
+	if instance.IsWindows() {
+		maxLength = config.MaxNetBIOSLength
+	}
+	if err := config.ValidateHostname(short, maxLength); err != nil {
+		return fmt.Sprintf("%v; serving %q instead", err, served)
+	}
+
+	if fqdn != "" {
+		if err := config.ValidateFQDN(fqdn); err != nil {
+			if servedFQDN == "" {
+				return fmt.Sprintf("%v; serving no FQDN", err)
+			}
+			return fmt.Sprintf("%v; serving %q instead", err, servedFQDN)
+		}
+	}
+	return ""
+}
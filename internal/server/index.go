@@ -0,0 +1,90 @@
+package server
+
+import (
+	"cyber-range-config/internal/config"
+)
+
+// snapshot is one load of the instance source with the lookups derived from it
+// It is built once per load and never modified, so readers need no lock
+type snapshot struct {
+	instances  []config.LXDInstance
+	byMAC      map[string]macOwner // Normalized MAC -> first instance and device using it
+	byName     map[string]int      // Instance name -> index in instances
+	issues     []Issue             // Validation problems in instances
+	collisions map[string][]string // MACs shared by several instances
+}
+
+// macOwner locates the NIC a MAC belongs to
+type macOwner struct {
+	index  int    // Index in snapshot.instances
+	device string // LXD device name
+}
+
+// newSnapshot indexes the instances by MAC and name
+func newSnapshot(instances []config.LXDInstance, issues []Issue) *snapshot {
+	snap := &snapshot{
+		instances:  instances,
+		byMAC:      make(map[string]macOwner),
+		byName:     make(map[string]int, len(instances)),
+		issues:     issues,
+		collisions: findMACCollisions(instances),
+	}
+
+	for i := range instances {
+		if _, ok := snap.byName[instances[i].Name]; !ok {
+			snap.byName[instances[i].Name] = i
+		}
+
+		// Sorted devices keep the owner of a MAC repeated within an instance stable
+		macs := instanceMACs(&instances[i])
+		for _, device := range sortedKeys(macs) {
+			if _, ok := snap.byMAC[macs[device]]; !ok {
+				snap.byMAC[macs[device]] = macOwner{index: i, device: device}
+			}
+		}
+	}
+
+	return snap
+}
+
+// lookupMAC returns the instance owning a normalized MAC and the LXD device name, or nil
+func (snap *snapshot) lookupMAC(mac string) (*config.LXDInstance, string) {
+	owner, ok := snap.byMAC[mac]
+	if !ok {
+		return nil, ""
+	}
+	return &snap.instances[owner.index], owner.device
+}
+
+// lookupMACs returns the instance and device owning the first of macs that belongs
+// to exactly one instance, and that MAC. MACs shared by several instances are skipped;
+// if only those match, the instance is nil and the first shared MAC is returned
+func (snap *snapshot) lookupMACs(macs []string) (*config.LXDInstance, string, string) {
+	shared := ""
+	for _, mac := range macs {
+		if len(snap.collisions[mac]) > 0 {
+			if shared == "" {
+				shared = mac
+			}
+			continue
+		}
+		if instance, device := snap.lookupMAC(mac); instance != nil {
+			return instance, device, mac
+		}
+	}
+	return nil, "", shared
+}
+
+// lookupName returns the instance with the given name, or nil
+func (snap *snapshot) lookupName(name string) *config.LXDInstance {
+	i, ok := snap.byName[name]
+	if !ok {
+		return nil
+	}
+	return &snap.instances[i]
+}
+
+// snapshot returns the instances currently being served
+func (s *Server) snapshot() *snapshot {
+	return s.state.Load()
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"cyber-range-config/internal/config"
+)
+
+// benchSizes are the instance counts the lookup benchmarks run at
+var benchSizes = []int{10, 100, 1000, 10000}
+
+// staticSource serves a fixed list of instances
+type staticSource []config.LXDInstance
+
+func (s staticSource) Load() ([]config.LXDInstance, error) { return s, nil }
+func (s staticSource) String() string                      { return "static" }
+
+// benchInstances builds n instances with two NICs and a static network config each
+func benchInstances(n int) []config.LXDInstance {
+	instances := make([]config.LXDInstance, n)
+	for i := range instances {
+		instances[i] = config.LXDInstance{
+			Name: fmt.Sprintf("vm%05d", i),
+			Config: map[string]string{
+				"image.os":             "ubuntu",
+				"limits.cpu":           "2",
+				"volatile.eth0.hwaddr": benchMAC(i, 0),
+				"volatile.eth1.hwaddr": benchMAC(i, 1),
+				"cloud-init.network-config": fmt.Sprintf(
+					"version: 2\nethernets:\n  eth0:\n    addresses: [10.%d.%d.10/24]\n  eth1:\n    dhcp4: true\n",
+					i/256, i%256),
+			},
+		}
+	}
+	return instances
+}
+
+// benchMAC returns a unique MAC for NIC nic of instance i
+func benchMAC(i, nic int) string {
+	return fmt.Sprintf("00:16:3e:%02x:%02x:%02x", i>>8&0xff, i&0xff, nic)
+}
+
+func BenchmarkLookupMAC(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("instances=%d", n), func(b *testing.B) {
+			snap := newSnapshot(benchInstances(n), nil)
+			// The last instance is the worst case for a linear scan
+			mac := benchMAC(n-1, 1)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if instance, _ := snap.lookupMAC(mac); instance == nil {
+					b.Fatal("MAC not found")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHandleConfig(b *testing.B) {
+	// Request logging would dominate the measurement
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("instances=%d", n), func(b *testing.B) {
+			srv, err := NewServer(staticSource(benchInstances(n)))
+			if err != nil {
+				b.Fatalf("NewServer: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/config?mac="+benchMAC(n-1, 1), nil)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				srv.HandleConfig(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLookupMACs(t *testing.T) {
+	// web01 and web02 were cloned with a pinned MAC on eth0; eth1 is unique to each
+	nic := func(name, eth0, eth1 string) config.LXDInstance {
+		return config.LXDInstance{Name: name, Config: map[string]string{
+			"volatile.eth0.hwaddr": eth0,
+			"volatile.eth1.hwaddr": eth1,
+		}}
+	}
+	snap := newSnapshot([]config.LXDInstance{
+		nic("web01", "00:16:3e:00:00:01", "00:16:3e:00:01:01"),
+		nic("web02", "00:16:3e:00:00:01", "00:16:3e:00:02:01"),
+	}, nil)
+
+	tests := []struct {
+		name         string
+		macs         []string
+		wantInstance string
+		wantMAC      string
+	}{
+		{name: "unique MAC", macs: []string{"00:16:3e:00:01:01"}, wantInstance: "web01", wantMAC: "00:16:3e:00:01:01"},
+		{name: "shared MAC skipped for a unique one", macs: []string{"00:16:3e:00:00:01", "00:16:3e:00:02:01"}, wantInstance: "web02", wantMAC: "00:16:3e:00:02:01"},
+		{name: "only shared MACs", macs: []string{"00:16:3e:00:00:99", "00:16:3e:00:00:01"}, wantMAC: "00:16:3e:00:00:01"},
+		{name: "no match", macs: []string{"00:16:3e:00:00:99"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, _, mac := snap.lookupMACs(tt.macs)
+			name := ""
+			if instance != nil {
+				name = instance.Name
+			}
+			if name != tt.wantInstance || mac != tt.wantMAC {
+				t.Errorf("got %q via %q, want %q via %q", name, mac, tt.wantInstance, tt.wantMAC)
+			}
+		})
+	}
+}
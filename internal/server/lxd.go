@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cyber-range-config/internal/config"
+)
+
+// LXDSource loads instances from the LXD REST API
+type LXDSource struct {
+	baseURL string
+	project string
+	client  *http.Client
+}
+
+// lxdResponse is the standard LXD API response envelope
+type lxdResponse struct {
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error"`
+	ErrorCode  int             `json:"error_code"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// NewLXDSource creates a source that queries the LXD API at baseURL using the given HTTP client
+func NewLXDSource(baseURL, project string, client *http.Client) *LXDSource {
+	return &LXDSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		project: project,
+		client:  client,
+	}
+}
+
+// NewLXDClient builds an HTTPS client that authenticates to LXD with a client certificate
+// If caFile is empty, the system roots are used to verify the LXD server
+func NewLXDClient(certFile, keyFile, caFile string) (*http.Client, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load LXD client certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load LXD CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// Load queries /1.0/instances with recursion for the configured project
+func (l *LXDSource) Load() ([]config.LXDInstance, error) {
+	q := url.Values{}
+	q.Set("recursion", "1")
+	if l.project != "" {
+		q.Set("project", l.project)
+	}
+	reqURL := l.baseURL + "/1.0/instances?" + q.Encode()
+
+	resp, err := l.client.Get(reqURL)
+	if err != nil {
+		return nil, fmt.Errorf("LXD request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LXD response: %w", err)
+	}
+
+	var envelope lxdResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse LXD response (HTTP %d): %w", resp.StatusCode, err)
+	}
+
+	if envelope.Type == "error" || resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LXD returned %d: %s", resp.StatusCode, envelope.Error)
+	}
+
+	var instances []config.LXDInstance
+	if err := json.Unmarshal(envelope.Metadata, &instances); err != nil {
+		return nil, fmt.Errorf("failed to parse LXD instances: %w", err)
+	}
+
+	return instances, nil
+}
+
+// String returns the API URL and project
+func (l *LXDSource) String() string {
+	if l.project == "" {
+		return l.baseURL
+	}
+	return fmt.Sprintf("%s (project %s)", l.baseURL, l.project)
+}
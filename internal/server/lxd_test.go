@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLXDSourceLoad(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1.0/instances" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("recursion") != "1" || r.URL.Query().Get("project") != "range" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"type":"sync","status":"Success","status_code":200,"metadata":[
+			{"name":"web01","config":{"volatile.eth0.hwaddr":"00:16:3e:aa:bb:cc"}}
+		]}`)
+	}))
+	defer ts.Close()
+
+	src := NewLXDSource(ts.URL, "range", ts.Client())
+	instances, err := src.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Name != "web01" {
+		t.Fatalf("unexpected instances: %+v", instances)
+	}
+	if instances[0].Config["volatile.eth0.hwaddr"] != "00:16:3e:aa:bb:cc" {
+		t.Errorf("config not decoded: %+v", instances[0].Config)
+	}
+}
+
+func TestLXDSourceError(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"error","error":"Project not found","error_code":404}`)
+	}))
+	defer ts.Close()
+
+	_, err := NewLXDSource(ts.URL, "missing", ts.Client()).Load()
+	if err == nil || !strings.Contains(err.Error(), "Project not found") {
+		t.Fatalf("expected LXD error, got %v", err)
+	}
+}
+
+func TestServerReloadOnMiss(t *testing.T) {
+	calls := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		instances := `[]`
+		if calls > 1 {
+			instances = `[{"name":"new01","config":{"volatile.eth0.hwaddr":"00:16:3e:00:00:01"}}]`
+		}
+		fmt.Fprintf(w, `{"type":"sync","status_code":200,"metadata":%s}`, instances)
+	}))
+	defer ts.Close()
+
+	srv, err := NewServer(NewLXDSource(ts.URL, "", ts.Client()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.SetReloadOnMiss(true)
+	// Pretend the initial load happened long ago so the miss may reload
+	srv.lastReload = srv.lastReload.Add(-minMissReloadInterval)
+
+	rec := httptest.NewRecorder()
+	srv.HandleConfig(rec, httptest.NewRequest(http.MethodGet, "/config?mac=00:16:3e:00:00:01", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after reload, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"hostname":"new01"`) {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestServerReloadOnMissRateLimited(t *testing.T) {
+	// LXD is up for the initial load and down afterwards
+	var calls atomic.Int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"type":"sync","status_code":200,"metadata":[]}`)
+	}))
+	defer ts.Close()
+
+	srv, err := NewServer(NewLXDSource(ts.URL, "", ts.Client()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.SetReloadOnMiss(true)
+	srv.lastReload = srv.lastReload.Add(-minMissReloadInterval)
+
+	// Concurrent and repeated misses share one reload attempt, even though it fails
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			srv.HandleConfig(rec, httptest.NewRequest(http.MethodGet, "/config?mac=00:16:3e:00:00:01", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("expected 404, got %d", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected the initial load and one miss reload, got %d LXD calls", got)
+	}
+}
+
+func TestExpandedConfigAndDevices(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Network config comes from a profile and the MAC is pinned on the NIC device
+		fmt.Fprint(w, `{"type":"sync","status_code":200,"metadata":[{
+			"name":"web01","type":"virtual-machine","project":"range","status":"Running","profiles":["default","lan"],
+			"config":{},
+			"expanded_config":{"cloud-init.network-config":"version: 2\nethernets:\n  eth0:\n    addresses: [10.0.0.5/24]\n"},
+			"expanded_devices":{"eth0":{"type":"nic","network":"lan","hwaddr":"00:16:3E:AA:BB:CC"},"root":{"type":"disk","path":"/"}}
+		}]}`)
+	}))
+	defer ts.Close()
+
+	srv, err := NewServer(NewLXDSource(ts.URL, "range", ts.Client()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.HandleConfig(rec, httptest.NewRequest(http.MethodGet, "/config?mac=00:16:3e:aa:bb:cc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"interface":"eth0"`) || !strings.Contains(body, `"10.0.0.5/24"`) {
+		t.Errorf("profile network config not used: %s", body)
+	}
+	if issues := srv.Issues(); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+}
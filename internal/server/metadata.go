@@ -0,0 +1,31 @@
+package server
+
+import (
+	"strings"
+
+	"cyber-range-config/internal/config"
+	"cyber-range-config/internal/token"
+)
+
+// MetadataPrefix marks the instance config keys passed to the guest as metadata
+const MetadataPrefix = "user.cyber-range."
+
+// instanceMetadata returns the instance's user.cyber-range.* keys without the
+// prefix, profiles applied; the bootstrap token is never included
+func instanceMetadata(instance *config.LXDInstance) map[string]string {
+	var metadata map[string]string
+	for key, value := range instance.EffectiveConfig() {
+		if !strings.HasPrefix(key, MetadataPrefix) || key == token.ConfigKey {
+			continue
+		}
+		name := strings.TrimPrefix(key, MetadataPrefix)
+		if name == "" {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[name] = value
+	}
+	return metadata
+}
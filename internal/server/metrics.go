@@ -0,0 +1,206 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds (seconds) of the request latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey identifies a request counter series
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // Per bucket in latencyBuckets, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+// metrics holds the counters exposed on /metrics
+type metrics struct {
+	mu             sync.Mutex
+	requests       map[requestKey]uint64
+	latency        map[string]*histogram
+	macMisses      uint64
+	tokenRejects   uint64
+	sourceRejects  uint64
+	reloads        uint64
+	reloadFailures uint64
+}
+
+// newMetrics creates an empty metrics set
+func newMetrics() *metrics {
+	return &metrics{
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[string]*histogram),
+	}
+}
+
+// observeRequest records a finished request
+func (m *metrics) observeRequest(endpoint string, code int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{endpoint, code}]++
+
+	h, ok := m.latency[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[endpoint] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// macMiss records a MAC lookup that found no instance
+func (m *metrics) macMiss() {
+	m.mu.Lock()
+	m.macMisses++
+	m.mu.Unlock()
+}
+
+// tokenRejected records a config request or report with a missing or invalid bootstrap token
+func (m *metrics) tokenRejected() {
+	m.mu.Lock()
+	m.tokenRejects++
+	m.mu.Unlock()
+}
+
+// sourceRejected records a config request whose source did not match the instance
+func (m *metrics) sourceRejected() {
+	m.mu.Lock()
+	m.sourceRejects++
+	m.mu.Unlock()
+}
+
+// reload records a reload and whether it failed
+func (m *metrics) reload(err error) {
+	m.mu.Lock()
+	m.reloads++
+	if err != nil {
+		m.reloadFailures++
+	}
+	m.mu.Unlock()
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// instrument wraps a handler to count requests and time them under the given endpoint name
+func (s *Server) instrument(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next(rec, r)
+		s.metrics.observeRequest(endpoint, rec.code, time.Since(start))
+	}
+}
+
+// HandleMetrics handles GET /metrics in the Prometheus text exposition format
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instanceCount := s.instanceCount()
+
+	var b strings.Builder
+	m := s.metrics
+	m.mu.Lock()
+
+	writeHeader(&b, "cyber_range_http_requests_total", "counter", "HTTP requests by endpoint and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&b, "cyber_range_http_requests_total{endpoint=%q,code=\"%d\"} %d\n", key.endpoint, key.code, m.requests[key])
+	}
+
+	writeHeader(&b, "cyber_range_http_request_duration_seconds", "histogram", "HTTP request latency by endpoint.")
+	endpoints := make([]string, 0, len(m.latency))
+	for endpoint := range m.latency {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		h := m.latency[endpoint]
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "cyber_range_http_request_duration_seconds_bucket{endpoint=%q,le=%q} %d\n", endpoint, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(&b, "cyber_range_http_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", endpoint, h.count)
+		fmt.Fprintf(&b, "cyber_range_http_request_duration_seconds_sum{endpoint=%q} %s\n", endpoint, formatFloat(h.sum))
+		fmt.Fprintf(&b, "cyber_range_http_request_duration_seconds_count{endpoint=%q} %d\n", endpoint, h.count)
+	}
+
+	writeHeader(&b, "cyber_range_mac_lookup_misses_total", "counter", "Config requests whose MAC matched no instance.")
+	fmt.Fprintf(&b, "cyber_range_mac_lookup_misses_total %d\n", m.macMisses)
+
+	writeHeader(&b, "cyber_range_token_rejections_total", "counter", "Config requests and reports rejected for a missing or invalid bootstrap token.")
+	fmt.Fprintf(&b, "cyber_range_token_rejections_total %d\n", m.tokenRejects)
+
+	writeHeader(&b, "cyber_range_source_rejections_total", "counter", "Config requests rejected because the source did not match the instance (strict mode).")
+	fmt.Fprintf(&b, "cyber_range_source_rejections_total %d\n", m.sourceRejects)
+
+	writeHeader(&b, "cyber_range_reloads_total", "counter", "Instance source reloads.")
+	fmt.Fprintf(&b, "cyber_range_reloads_total %d\n", m.reloads)
+
+	writeHeader(&b, "cyber_range_reload_failures_total", "counter", "Instance source reloads that failed.")
+	fmt.Fprintf(&b, "cyber_range_reload_failures_total %d\n", m.reloadFailures)
+
+	m.mu.Unlock()
+
+	writeHeader(&b, "cyber_range_instances_loaded", "gauge", "Instances currently loaded from the source.")
+	fmt.Fprintf(&b, "cyber_range_instances_loaded %d\n", instanceCount)
+
+	writeHeader(&b, "cyber_range_seconds_since_last_activity", "gauge", "Seconds since the last client or admin request.")
+	fmt.Fprintf(&b, "cyber_range_seconds_since_last_activity %s\n", formatFloat(time.Since(s.GetLastActivity()).Seconds()))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric family
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatFloat renders a float the way Prometheus expects
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
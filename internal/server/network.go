@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"cyber-range-config/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseAllNetworkConfigs parses all devices (ethernets, bonds, bridges, vlans) from the instance network config
+func (s *Server) parseAllNetworkConfigs(instance *config.LXDInstance) map[string]config.NetworkConfig {
+	networks := make(map[string]config.NetworkConfig)
+
+	cloudInit, err := decodeNetworkConfig(instance)
+	if err != nil {
+		log.Printf("Failed to parse cloud-init config for %s: %v", instance.Name, err)
+		return networks
+	}
+	if cloudInit == nil {
+		return networks // Empty map, client will use DHCP
+	}
+
+	// Parse ALL ethernet devices
+	macs := instanceMACs(instance)
+	for ifaceName, eth := range cloudInit.Ethernets {
+		networks[ifaceName] = ethernetToNetworkConfig(macs[ifaceName], eth)
+	}
+
+	// Virtual devices reference the ethernets (and each other) by name
+	for name, bond := range cloudInit.Bonds {
+		netConfig := interfaceToNetworkConfig(bond.CloudInitInterface)
+		netConfig.Kind = config.KindBond
+		netConfig.Members = bond.Interfaces
+		netConfig.Parameters = stringParameters(bond.Parameters)
+		networks[name] = netConfig
+	}
+
+	for name, bridge := range cloudInit.Bridges {
+		netConfig := interfaceToNetworkConfig(bridge.CloudInitInterface)
+		netConfig.Kind = config.KindBridge
+		netConfig.Members = bridge.Interfaces
+		netConfig.Parameters = stringParameters(bridge.Parameters)
+		networks[name] = netConfig
+	}
+
+	for name, vlan := range cloudInit.VLANs {
+		netConfig := interfaceToNetworkConfig(vlan.CloudInitInterface)
+		netConfig.Kind = config.KindVLAN
+		netConfig.Link = vlan.Link
+		netConfig.VLANID = vlan.ID
+		networks[name] = netConfig
+	}
+
+	return networks
+}
+
+// networkConfigKeys are the instance config keys holding cloud-init network
+// config, in order of preference; user.network-config is the pre-cloud-init.* name
+var networkConfigKeys = []string{"cloud-init.network-config", "user.network-config"}
+
+// networkConfig returns the first network config key the instance sets, and its value
+func networkConfig(instance *config.LXDInstance) (string, string) {
+	for _, key := range networkConfigKeys {
+		if value := instance.ConfigValue(key); value != "" {
+			return key, value
+		}
+	}
+	return networkConfigKeys[0], ""
+}
+
+// decodeNetworkConfig parses the instance's effective network config (which may
+// come from a profile) as netplan v2 or cloud-init v1 YAML, v1 converted to v2
+// Returns nil for the simple "DHCP" string
+func decodeNetworkConfig(instance *config.LXDInstance) (*config.CloudInitNetwork, error) {
+	_, cloudInitConfig := networkConfig(instance)
+
+	// Check for simple DHCP string
+	if strings.TrimSpace(strings.ToUpper(cloudInitConfig)) == "DHCP" {
+		return nil, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(cloudInitConfig), &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return &config.CloudInitNetwork{}, nil // Empty document
+	}
+
+	// cloud-init also accepts the config nested under a top-level network key
+	node := &root
+	var wrapper struct {
+		Network yaml.Node `yaml:"network"`
+	}
+	if err := root.Decode(&wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Network.Kind != 0 {
+		node = &wrapper.Network
+	}
+
+	var version struct {
+		Version int `yaml:"version"`
+	}
+	if err := node.Decode(&version); err != nil {
+		return nil, err
+	}
+
+	if version.Version == 1 {
+		var v1 config.CloudInitV1Network
+		if err := node.Decode(&v1); err != nil {
+			return nil, err
+		}
+		return convertV1(&v1)
+	}
+
+	var cloudInit config.CloudInitNetwork
+	if err := node.Decode(&cloudInit); err != nil {
+		return nil, err
+	}
+	return &cloudInit, nil
+}
+
+// ethernetToNetworkConfig converts a netplan ethernet entry into the client model
+// mac is the hardware address of the LXD NIC device with the same name, if any
+func ethernetToNetworkConfig(mac string, eth config.CloudInitEthernet) config.NetworkConfig {
+	netConfig := interfaceToNetworkConfig(eth.CloudInitInterface)
+	netConfig.Kind = config.KindEthernet
+	netConfig.SetName = eth.SetName
+
+	// Hardware address lets clients find the NIC regardless of its guest name
+	netConfig.MAC = mac
+	if netConfig.MAC == "" {
+		netConfig.MAC = strings.ToLower(eth.Match.MACAddress)
+	}
+
+	return netConfig
+}
+
+// interfaceToNetworkConfig converts the addressing keys shared by all netplan device types
+func interfaceToNetworkConfig(iface config.CloudInitInterface) config.NetworkConfig {
+	netConfig := config.NetworkConfig{
+		DHCP:      iface.DHCP4,
+		DHCP6:     iface.DHCP6,
+		Addresses: iface.Addresses,
+		Gateway:   iface.Gateway4,
+		Gateway6:  iface.Gateway6,
+		MTU:       iface.MTU,
+		DNS:       iface.Nameservers.Addresses,
+		Search:    iface.Nameservers.Search,
+	}
+
+	// SLAAC only when explicitly requested, DHCPv6 implies listening to RAs anyway
+	if iface.AcceptRA != nil {
+		netConfig.AcceptRA = *iface.AcceptRA
+	}
+
+	// First address for static IP (backwards compat), preferring IPv4 for older clients
+	if v4 := netConfig.IPv4Addresses(); len(v4) > 0 {
+		netConfig.Address = v4[0]
+	} else if len(iface.Addresses) > 0 {
+		netConfig.Address = iface.Addresses[0]
+	}
+
+	// Process routes - extract default gateways and collect custom routes
+	for _, route := range iface.Routes {
+		ipv6 := strings.Contains(route.Via, ":")
+		isDefault := route.To == "default" || route.To == "0.0.0.0/0" || route.To == "::/0"
+
+		// Default routes in a custom table are policy routes, keep them as-is
+		if isDefault && route.Table == 0 {
+			if ipv6 && netConfig.Gateway6 == "" {
+				netConfig.Gateway6 = route.Via
+				continue
+			}
+			if !ipv6 && netConfig.Gateway == "" {
+				netConfig.Gateway = route.Via
+				netConfig.Metric = route.Metric
+				continue
+			}
+		}
+
+		// Custom route - normalize "default" so clients get a real prefix
+		if route.To == "default" {
+			route.To = "0.0.0.0/0"
+			if ipv6 {
+				route.To = "::/0"
+			}
+		}
+		netConfig.Routes = append(netConfig.Routes, route)
+	}
+
+	return netConfig
+}
+
+// stringParameters flattens netplan bond/bridge parameters into strings
+func stringParameters(params map[string]interface{}) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	flat := make(map[string]string, len(params))
+	for key, value := range params {
+		flat[key] = fmt.Sprint(value)
+	}
+	return flat
+}
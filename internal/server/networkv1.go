@@ -0,0 +1,297 @@
+package server
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"cyber-range-config/internal/config"
+)
+
+// v1BondParams maps v1 (ifupdown-style) bond params to their netplan names
+var v1BondParams = map[string]string{
+	"bond-mode":             "mode",
+	"bond-miimon":           "mii-monitor-interval",
+	"bond-xmit-hash-policy": "transmit-hash-policy",
+	"bond-lacp-rate":        "lacp-rate",
+	"bond-primary":          "primary",
+	"bond-primary-reselect": "primary-reselect-policy",
+	"bond-updelay":          "up-delay",
+	"bond-downdelay":        "down-delay",
+	"bond-ad-select":        "ad-select",
+	"bond-arp-interval":     "arp-interval",
+	"bond-min-links":        "min-links",
+	"bond-fail-over-mac":    "fail-over-mac-policy",
+}
+
+// v1BridgeParams maps v1 bridge params to their netplan names
+var v1BridgeParams = map[string]string{
+	"bridge_stp":        "stp",
+	"bridge_fd":         "forward-delay",
+	"bridge_bridgeprio": "priority",
+	"bridge_hello":      "hello-time",
+	"bridge_maxage":     "max-age",
+	"bridge_ageing":     "ageing-time",
+	"bridge_pathcost":   "path-cost",
+	"bridge_portprio":   "port-priority",
+}
+
+// v1Device is a v1 interface entry with its converted addressing
+type v1Device struct {
+	entry config.CloudInitV1Entry
+	iface config.CloudInitInterface
+}
+
+// convertV1 translates cloud-init v1 network config into the netplan v2 model
+// so the rest of the server only deals with one format
+func convertV1(v1 *config.CloudInitV1Network) (*config.CloudInitNetwork, error) {
+	var devices []*v1Device
+	var nameservers config.CloudInitNameservers
+	var globalRoutes []config.CloudInitV1Route
+
+	for i, entry := range v1.Config {
+		switch entry.Type {
+		case "nameserver":
+			nameservers.Addresses = append(nameservers.Addresses, entry.Address...)
+			nameservers.Search = append(nameservers.Search, entry.Search...)
+		case "route":
+			globalRoutes = append(globalRoutes, entry.CloudInitV1Route)
+		case "loopback":
+		case "physical", "bond", "bridge", "vlan":
+			if entry.Name == "" {
+				return nil, fmt.Errorf("config entry %d (%s) has no name", i, entry.Type)
+			}
+			iface, err := v1Interface(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name, err)
+			}
+			devices = append(devices, &v1Device{entry: entry, iface: iface})
+		default:
+			return nil, fmt.Errorf("config entry %d: unsupported type %q", i, entry.Type)
+		}
+	}
+
+	// Global routes belong to the first interface whose subnet holds their gateway,
+	// or else to the first DHCP interface, whose subnet is only known at runtime
+	for _, route := range globalRoutes {
+		r, err := v1Route(route)
+		if err != nil {
+			return nil, err
+		}
+		device := v1RouteDevice(devices, r.Via)
+		if device == nil {
+			device = v1DHCPDevice(devices, strings.Contains(r.Via, ":"))
+		}
+		if device == nil {
+			return nil, fmt.Errorf("route to %s via %s is not on any interface subnet", r.To, r.Via)
+		}
+		device.iface.Routes = append(device.iface.Routes, r)
+	}
+
+	cloudInit := &config.CloudInitNetwork{Version: 2}
+	for _, device := range devices {
+		// Global nameservers apply to every interface without its own
+		if len(device.iface.Nameservers.Addresses) == 0 && len(device.iface.Nameservers.Search) == 0 {
+			device.iface.Nameservers = nameservers
+		}
+		addV1Device(cloudInit, device)
+	}
+
+	return cloudInit, nil
+}
+
+// addV1Device adds a converted v1 interface to the netplan model under its name
+func addV1Device(cloudInit *config.CloudInitNetwork, device *v1Device) {
+	entry := device.entry
+
+	switch entry.Type {
+	case "physical":
+		eth := config.CloudInitEthernet{CloudInitInterface: device.iface}
+		// A v1 name with a MAC renames whatever NIC has that MAC
+		if entry.MACAddress != "" {
+			eth.Match.MACAddress = strings.ToLower(entry.MACAddress)
+			eth.SetName = entry.Name
+		}
+		if cloudInit.Ethernets == nil {
+			cloudInit.Ethernets = make(map[string]config.CloudInitEthernet)
+		}
+		cloudInit.Ethernets[entry.Name] = eth
+	case "bond":
+		if cloudInit.Bonds == nil {
+			cloudInit.Bonds = make(map[string]config.CloudInitBond)
+		}
+		cloudInit.Bonds[entry.Name] = config.CloudInitBond{
+			Interfaces:         entry.BondInterfaces,
+			Parameters:         v1Params(entry.Params, v1BondParams, "bond-"),
+			CloudInitInterface: device.iface,
+		}
+	case "bridge":
+		if cloudInit.Bridges == nil {
+			cloudInit.Bridges = make(map[string]config.CloudInitBridge)
+		}
+		cloudInit.Bridges[entry.Name] = config.CloudInitBridge{
+			Interfaces:         entry.BridgeInterfaces,
+			Parameters:         v1Params(entry.Params, v1BridgeParams, "bridge_"),
+			CloudInitInterface: device.iface,
+		}
+	case "vlan":
+		if cloudInit.VLANs == nil {
+			cloudInit.VLANs = make(map[string]config.CloudInitVLAN)
+		}
+		cloudInit.VLANs[entry.Name] = config.CloudInitVLAN{
+			ID:                 entry.VLANID,
+			Link:               entry.VLANLink,
+			CloudInitInterface: device.iface,
+		}
+	}
+}
+
+// v1Interface converts the MTU and subnets of a v1 entry into netplan addressing keys
+func v1Interface(entry config.CloudInitV1Entry) (config.CloudInitInterface, error) {
+	iface := config.CloudInitInterface{MTU: entry.MTU}
+
+	for _, subnet := range entry.Subnets {
+		switch subnet.Type {
+		case "dhcp", "dhcp4":
+			iface.DHCP4 = true
+		case "dhcp6", "ipv6_dhcpv6-stateful":
+			iface.DHCP6 = true
+		case "ipv6_slaac", "ipv6_dhcpv6-stateless":
+			acceptRA := true
+			iface.AcceptRA = &acceptRA
+		case "static", "static6":
+			address, err := v1Address(subnet.Address, subnet.Netmask)
+			if err != nil {
+				return iface, err
+			}
+			iface.Addresses = append(iface.Addresses, address)
+
+			if subnet.Gateway != "" {
+				if strings.Contains(subnet.Gateway, ":") {
+					iface.Gateway6 = subnet.Gateway
+				} else {
+					iface.Gateway4 = subnet.Gateway
+				}
+			}
+		case "manual", "":
+		default:
+			return iface, fmt.Errorf("unsupported subnet type %q", subnet.Type)
+		}
+
+		iface.Nameservers.Addresses = append(iface.Nameservers.Addresses, subnet.DNSNameservers...)
+		iface.Nameservers.Search = append(iface.Nameservers.Search, subnet.DNSSearch...)
+
+		for _, route := range subnet.Routes {
+			r, err := v1Route(route)
+			if err != nil {
+				return iface, err
+			}
+			iface.Routes = append(iface.Routes, r)
+		}
+	}
+
+	return iface, nil
+}
+
+// v1Address returns address in CIDR form, applying a dotted or prefix-length netmask
+func v1Address(address, netmask string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("static subnet has no address")
+	}
+	if strings.Contains(address, "/") || netmask == "" {
+		return address, nil
+	}
+
+	bits, err := prefixLength(netmask)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d", address, bits), nil
+}
+
+// v1Route converts a v1 route into a netplan route
+func v1Route(route config.CloudInitV1Route) (config.Route, error) {
+	to := route.Destination
+	if to == "" {
+		if route.Network == "" {
+			return config.Route{}, fmt.Errorf("route via %s has no network or destination", route.Gateway)
+		}
+		var err error
+		if to, err = v1Address(route.Network, route.Netmask); err != nil {
+			return config.Route{}, err
+		}
+	}
+	return config.Route{To: to, Via: route.Gateway, Metric: route.Metric}, nil
+}
+
+// v1RouteDevice returns the first interface with a static address whose subnet contains gateway
+func v1RouteDevice(devices []*v1Device, gateway string) *v1Device {
+	ip, err := netip.ParseAddr(gateway)
+	if err != nil {
+		return nil
+	}
+
+	for _, device := range devices {
+		for _, address := range device.iface.Addresses {
+			if prefix, err := netip.ParsePrefix(address); err == nil && prefix.Masked().Contains(ip) {
+				return device
+			}
+		}
+	}
+	return nil
+}
+
+// v1DHCPDevice returns the first interface using DHCP for the gateway's address family
+func v1DHCPDevice(devices []*v1Device, ipv6 bool) *v1Device {
+	for _, device := range devices {
+		if ipv6 && device.iface.DHCP6 || !ipv6 && device.iface.DHCP4 {
+			return device
+		}
+	}
+	return nil
+}
+
+// prefixLength parses a netmask given as a prefix length ("24") or dotted quad ("255.255.255.0")
+func prefixLength(netmask string) (int, error) {
+	if bits, err := strconv.Atoi(netmask); err == nil {
+		return bits, nil
+	}
+
+	mask, err := netip.ParseAddr(netmask)
+	if err != nil || !mask.Is4() {
+		return 0, fmt.Errorf("invalid netmask %q", netmask)
+	}
+
+	bits, seenZero := 0, false
+	for _, b := range mask.As4() {
+		for i := 7; i >= 0; i-- {
+			if b&(1<<i) == 0 {
+				seenZero = true
+				continue
+			}
+			if seenZero {
+				return 0, fmt.Errorf("invalid netmask %q", netmask)
+			}
+			bits++
+		}
+	}
+	return bits, nil
+}
+
+// v1Params renames v1 params to netplan names, stripping prefix from unknown ones
+func v1Params(params map[string]interface{}, names map[string]string, prefix string) map[string]interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+
+	renamed := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		name, ok := names[key]
+		if !ok {
+			name = strings.ReplaceAll(strings.TrimPrefix(key, prefix), "_", "-")
+		}
+		renamed[name] = value
+	}
+	return renamed
+}
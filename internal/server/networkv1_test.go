@@ -0,0 +1,164 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"cyber-range-config/internal/config"
+)
+
+func TestNetworkConfigV1(t *testing.T) {
+	instance := &config.LXDInstance{
+		Name: "legacy01",
+		Config: map[string]string{
+			"volatile.eth0.hwaddr": "00:16:3e:00:00:01",
+			// Older images set the pre-cloud-init.* key, wrapped in network:
+			"user.network-config": `network:
+  version: 1
+  config:
+    - type: physical
+      name: eth0
+      subnets:
+        - type: static
+          address: 192.168.1.10
+          netmask: 255.255.255.0
+          routes:
+            - network: 10.0.0.0
+              netmask: 255.0.0.0
+              gateway: 192.168.1.254
+    - type: physical
+      name: eth1
+      mac_address: "00:16:3E:00:00:02"
+    - type: physical
+      name: eth2
+    - type: bond
+      name: bond0
+      bond_interfaces: [eth1, eth2]
+      params:
+        bond-mode: active-backup
+        bond-miimon: 100
+      subnets:
+        - type: dhcp
+        - type: static6
+          address: fd00::10/64
+    - type: nameserver
+      address: [192.168.1.1]
+      search: [range.local]
+    - type: route
+      destination: 0.0.0.0/0
+      gateway: 192.168.1.1
+`,
+		},
+	}
+
+	srv := &Server{}
+	if issues := srv.Validate([]config.LXDInstance{*instance}); len(issues) != 0 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+
+	networks := srv.parseAllNetworkConfigs(instance)
+
+	eth0 := networks["eth0"]
+	if eth0.MAC != "00:16:3e:00:00:01" || eth0.Address != "192.168.1.10/24" || eth0.Gateway != "192.168.1.1" {
+		t.Errorf("eth0: %+v", eth0)
+	}
+	if want := []config.Route{{To: "10.0.0.0/8", Via: "192.168.1.254"}}; !reflect.DeepEqual(eth0.Routes, want) {
+		t.Errorf("eth0 routes: %+v", eth0.Routes)
+	}
+	if !reflect.DeepEqual(eth0.DNS, []string{"192.168.1.1"}) || !reflect.DeepEqual(eth0.Search, []string{"range.local"}) {
+		t.Errorf("eth0 DNS: %v %v", eth0.DNS, eth0.Search)
+	}
+
+	if eth1 := networks["eth1"]; eth1.MAC != "00:16:3e:00:00:02" || eth1.SetName != "eth1" {
+		t.Errorf("eth1: %+v", eth1)
+	}
+
+	bond := networks["bond0"]
+	if bond.Kind != config.KindBond || !bond.DHCP || !reflect.DeepEqual(bond.Addresses, []string{"fd00::10/64"}) {
+		t.Errorf("bond0: %+v", bond)
+	}
+	if want := map[string]string{"mode": "active-backup", "mii-monitor-interval": "100"}; !reflect.DeepEqual(bond.Parameters, want) {
+		t.Errorf("bond0 parameters: %v", bond.Parameters)
+	}
+}
+
+func TestNetworkConfigV1Forms(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		check   func(t *testing.T, eth0 config.NetworkConfig)
+	}{
+		{
+			name: "dhcpv6 stateful",
+			network: `version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets:
+      - type: dhcp
+      - type: ipv6_dhcpv6-stateful
+`,
+			check: func(t *testing.T, eth0 config.NetworkConfig) {
+				if !eth0.DHCP || !eth0.DHCP6 {
+					t.Errorf("want DHCP and DHCPv6: %+v", eth0)
+				}
+			},
+		},
+		{
+			name: "global route on a DHCP interface",
+			network: `version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets:
+      - type: dhcp
+  - type: route
+    network: 10.0.0.0
+    netmask: 255.0.0.0
+    gateway: 172.16.0.1
+`,
+			check: func(t *testing.T, eth0 config.NetworkConfig) {
+				if want := []config.Route{{To: "10.0.0.0/8", Via: "172.16.0.1"}}; !reflect.DeepEqual(eth0.Routes, want) {
+					t.Errorf("routes: %+v", eth0.Routes)
+				}
+			},
+		},
+		{
+			name: "scalar nameserver address",
+			network: `version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets:
+      - type: static
+        address: 192.168.1.10/24
+  - type: nameserver
+    address: 192.168.1.1
+    search: range.local
+`,
+			check: func(t *testing.T, eth0 config.NetworkConfig) {
+				if !reflect.DeepEqual(eth0.DNS, []string{"192.168.1.1"}) || !reflect.DeepEqual(eth0.Search, []string{"range.local"}) {
+					t.Errorf("DNS: %v %v", eth0.DNS, eth0.Search)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &config.LXDInstance{
+				Name: "v1",
+				Config: map[string]string{
+					"volatile.eth0.hwaddr":      "00:16:3e:00:00:01",
+					"cloud-init.network-config": tt.network,
+				},
+			}
+
+			srv := &Server{}
+			if issues := srv.Validate([]config.LXDInstance{*instance}); len(issues) != 0 {
+				t.Fatalf("unexpected issues: %v", issues)
+			}
+			tt.check(t, srv.parseAllNetworkConfigs(instance)["eth0"])
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"cyber-range-config/internal/config"
+)
+
+// Progress summarizes which expected instances have checked in
+type Progress struct {
+	Expected    int      `json:"expected"`              // Instances with a NIC that can request config
+	Fetched     int      `json:"fetched"`               // Instances that fetched (or reported) their config
+	Confirmed   int      `json:"confirmed"`             // Instances that posted a report
+	Missing     []string `json:"missing,omitempty"`     // Never requested config
+	Unconfirmed []string `json:"unconfirmed,omitempty"` // Fetched config but never reported
+}
+
+// Done reports whether every expected instance fetched its config,
+// or confirmed it with a report when requireReport is set
+func (p Progress) Done(requireReport bool) bool {
+	if p.Expected == 0 {
+		return false
+	}
+	if requireReport {
+		return p.Confirmed == p.Expected
+	}
+	return p.Fetched == p.Expected
+}
+
+// markFetched records that an instance was served its config
+func (s *Server) markFetched(name string) {
+	s.reportsMu.Lock()
+	if _, ok := s.fetched[name]; !ok {
+		s.fetched[name] = time.Now()
+	}
+	s.reportsMu.Unlock()
+}
+
+// Progress compares the instances from the source with the check-ins so far
+func (s *Server) Progress() Progress {
+	var expected []string
+	instances := s.snapshot().instances
+	for i := range instances {
+		if hasHwaddr(&instances[i]) {
+			expected = append(expected, instances[i].Name)
+		}
+	}
+	sort.Strings(expected)
+
+	s.reportsMu.RLock()
+	defer s.reportsMu.RUnlock()
+
+	p := Progress{Expected: len(expected)}
+	for _, name := range expected {
+		_, fetched := s.fetched[name]
+		_, confirmed := s.reports[name]
+
+		switch {
+		case confirmed:
+			p.Fetched++
+			p.Confirmed++
+		case fetched:
+			p.Fetched++
+			p.Unconfirmed = append(p.Unconfirmed, name)
+		default:
+			p.Missing = append(p.Missing, name)
+		}
+	}
+
+	return p
+}
+
+// hasHwaddr reports whether the instance config has a MAC that /config can match
+func hasHwaddr(instance *config.LXDInstance) bool {
+	return len(instance.NICHwaddrs()) > 0
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cyber-range-config/internal/config"
+)
+
+// maxReportSize limits the size of a client report body
+const maxReportSize = 1 << 20
+
+// HandleReport handles POST /report from clients after they apply their config
+func (s *Server) HandleReport(w http.ResponseWriter, r *http.Request) {
+	s.updateActivity()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Keep the raw body: the signature covers the exact bytes sent
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportSize))
+	if err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+
+	var report config.ClientReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+
+	if report.MAC == "" {
+		http.Error(w, "Report needs a 'mac'", http.StatusBadRequest)
+		return
+	}
+
+	// Reports are keyed by the instance owning the MAC, never by the reported hostname
+	sentMAC := report.MAC
+	report.MAC = normalizeMAC(report.MAC)
+	snap := s.snapshot()
+
+	// A shared MAC cannot say which instance reported, as for /config
+	if names := snap.collisions[report.MAC]; len(names) > 0 {
+		slog.Warn("Rejected report: MAC is used by several instances", "mac", report.MAC, "instances", names, "client", report.Client)
+		http.Error(w, fmt.Sprintf("MAC %s is used by several instances", report.MAC), http.StatusConflict)
+		return
+	}
+
+	instance, _ := snap.lookupMAC(report.MAC)
+	if instance == nil {
+		slog.Warn("Rejected report: no instance for MAC", "mac", report.MAC, "client", report.Client)
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+	name := instance.Name
+
+	logger := slog.With("instance", name, "client", report.Client, "request_id", report.RequestID)
+
+	// The reporter must hold the instance's bootstrap secret, as for /config
+	if err := s.verifyReport(instance, sentMAC, body, r); err != nil {
+		logger.Warn("Rejected report: bad bootstrap token", "error", err)
+		s.metrics.tokenRejected()
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	report.ReceivedAt = time.Now()
+
+	s.reportsMu.Lock()
+	s.reports[name] = report
+	s.reportsMu.Unlock()
+
+	if report.Success {
+		logger.Info("Client report: success", "interfaces", report.Interfaces, "timings", report.Timings)
+	} else {
+		logger.Warn("Client report: failed", "errors", report.Errors, "timings", report.Timings)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Report received for %s\n", name)
+}
+
+// Reports returns a copy of the latest report per instance
+func (s *Server) Reports() map[string]config.ClientReport {
+	s.reportsMu.RLock()
+	defer s.reportsMu.RUnlock()
+
+	reports := make(map[string]config.ClientReport, len(s.reports))
+	for name, report := range s.reports {
+		reports[name] = report
+	}
+	return reports
+}
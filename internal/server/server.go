@@ -1,36 +1,80 @@
 package server
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"cyber-range-config/internal/config"
-
-	"gopkg.in/yaml.v3"
+	"cyber-range-config/internal/logging"
+	"cyber-range-config/internal/signing"
 )
 
+// minMissReloadInterval limits how often a lookup miss may trigger a reload
+const minMissReloadInterval = 5 * time.Second
+
 // Server handles HTTP requests for configuration
 type Server struct {
-	instancesFile string
-	instances     []config.LXDInstance
-	mu            sync.RWMutex
+	source InstanceSource
+	state  atomic.Pointer[snapshot] // Instances and their MAC index, swapped on each load
+
+	// Reject instances without a bootstrap token
+	requireToken bool
+
+	// Source address checks
+	allowedSources []netip.Prefix // Client endpoints; empty allows all
+	adminSources   []netip.Prefix // /reload, /status and /instances
+	adminToken     string         // Bearer token for the admin endpoints; empty allows any admin source
+	strictSource   bool           // Source must match the requested instance
+
+	// Signs config responses when set
+	responseKey ed25519.PrivateKey
+
+	// Derive hostnames from instance names; nil sends the instance name
+	hostnameRules *HostnameRules
+
+	// On-demand reload when a MAC is not found
+	reloadOnMiss bool
+	lastReload   time.Time
+	reloadErr    error // Error of the latest reload; the previous snapshot is still served
+	reloadMu     sync.Mutex
+
+	// Lookup-miss reloads run one at a time, and at most every minMissReloadInterval
+	// counted from the attempt, so a failing source is not hit on every miss
+	lastMissReload time.Time
+	missReloadMu   sync.Mutex
 
 	// Idle timeout tracking
 	lastActivity time.Time
 	activityMu   sync.RWMutex
+
+	// Client check-ins per instance name: config fetches and latest reports
+	fetched   map[string]time.Time
+	reports   map[string]config.ClientReport
+	reportsMu sync.RWMutex
+
+	metrics *metrics
 }
 
-// NewServer creates a new configuration server
-func NewServer(instancesFile string) (*Server, error) {
+// NewServer creates a new configuration server backed by the given instance source
+func NewServer(source InstanceSource) (*Server, error) {
 	s := &Server{
-		instancesFile: instancesFile,
-		lastActivity:  time.Now(),
+		source:       source,
+		lastActivity: time.Now(),
+		fetched:      make(map[string]time.Time),
+		reports:      make(map[string]config.ClientReport),
+		adminSources: defaultAdminSources,
+		metrics:      newMetrics(),
 	}
 
 	if err := s.loadInstances(); err != nil {
@@ -40,30 +84,136 @@ func NewServer(instancesFile string) (*Server, error) {
 	return s, nil
 }
 
-// loadInstances loads the LXD instances from the JSON file
+// loadInstances loads the LXD instances from the instance source
 func (s *Server) loadInstances() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	data, err := os.ReadFile(s.instancesFile)
+	// Fetch outside the lock so slow sources don't block lookups
+	instances, err := s.source.Load()
 	if err != nil {
-		return fmt.Errorf("failed to read instances file: %w", err)
+		return err
 	}
+	// Build the index before swapping it in, so lookups never see a partial load
+	issues := s.Validate(instances)
+	s.state.Store(newSnapshot(instances, issues))
 
-	var instances []config.LXDInstance
-	if err := json.Unmarshal(data, &instances); err != nil {
-		return fmt.Errorf("failed to parse instances JSON: %w", err)
-	}
+	s.reloadMu.Lock()
+	s.lastReload = time.Now()
+	s.reloadMu.Unlock()
 
-	s.instances = instances
-	log.Printf("Loaded %d instances from %s", len(instances), s.instancesFile)
+	log.Printf("Loaded %d instances from %s", len(instances), s.source)
+	logIssues(issues)
 
 	return nil
 }
 
-// Reload reloads the instances file (can be called to refresh)
+// Reload reloads the instances from the source (can be called to refresh)
+// On failure the previous instances stay loaded and the error shows in /status
 func (s *Server) Reload() error {
-	return s.loadInstances()
+	err := s.loadInstances()
+	s.metrics.reload(err)
+
+	s.reloadMu.Lock()
+	s.reloadErr = err
+	s.reloadMu.Unlock()
+
+	return err
+}
+
+// reloadState returns when instances were last loaded and the error of the latest reload
+func (s *Server) reloadState() (time.Time, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.lastReload, s.reloadErr
+}
+
+// SetReloadOnMiss makes the server reload its source when a MAC is not found
+func (s *Server) SetReloadOnMiss(enabled bool) {
+	s.reloadOnMiss = enabled
+}
+
+// StartRefresh reloads the instance source every interval until stop is closed
+func (s *Server) StartRefresh(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Reload(); err != nil {
+					log.Printf("Error refreshing instances: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StartWatch polls the instances file every interval and reloads when its
+// modification time or size changes, until stop is closed
+func (s *Server) StartWatch(path string, interval time.Duration, stop <-chan struct{}) {
+	last, _ := os.Stat(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || fileUnchanged(last, info) {
+					continue
+				}
+				last = info
+
+				log.Printf("%s changed, reloading", path)
+				if err := s.Reload(); err != nil {
+					log.Printf("Error reloading instances, keeping the previous %d: %v", s.instanceCount(), err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// fileUnchanged reports whether two stats of a file have the same modification time and size
+func fileUnchanged(a, b os.FileInfo) bool {
+	return a != nil && b != nil && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// instanceCount returns the number of loaded instances
+func (s *Server) instanceCount() int {
+	return len(s.snapshot().instances)
+}
+
+// reloadAfterMiss reloads the source if neither the last load nor the last miss
+// reload attempt is recent. Returns true if the caller should look up again
+func (s *Server) reloadAfterMiss() bool {
+	waitStart := time.Now()
+	s.missReloadMu.Lock()
+	defer s.missReloadMu.Unlock()
+
+	// Another miss reloaded while we waited; its result is already loaded
+	if s.lastMissReload.After(waitStart) {
+		return true
+	}
+
+	s.reloadMu.Lock()
+	recent := time.Since(s.lastReload) < minMissReloadInterval
+	s.reloadMu.Unlock()
+
+	if recent || time.Since(s.lastMissReload) < minMissReloadInterval {
+		return false
+	}
+	s.lastMissReload = time.Now()
+
+	if err := s.Reload(); err != nil {
+		log.Printf("Error reloading instances after lookup miss: %v", err)
+		return false
+	}
+	return true
 }
 
 // updateActivity updates the last activity timestamp
@@ -84,147 +234,197 @@ func (s *Server) GetLastActivity() time.Time {
 func (s *Server) HandleConfig(w http.ResponseWriter, r *http.Request) {
 	s.updateActivity()
 
+	// Tag the request so server and client logs can be correlated
+	requestID := logging.NewRequestID()
+	w.Header().Set(logging.RequestIDHeader, requestID)
+	logger := slog.With("request_id", requestID, "remote", r.RemoteAddr)
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get MAC address from query parameter
-	mac := r.URL.Query().Get("mac")
-	if mac == "" {
-		http.Error(w, "Missing 'mac' query parameter", http.StatusBadRequest)
+	// Clients send every MAC they have, as repeated mac query parameters
+	macs, err := requestMACs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	logger.Info("Config request", "macs", macs)
+
+	// Find instance by the first MAC that belongs to exactly one
+	snap := s.snapshot()
+	instance, device, mac := snap.lookupMACs(macs)
 
-	// Normalize MAC address
-	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
-	log.Printf("Config request for MAC: %s", mac)
+	// Instance may have been created since the last load
+	if instance == nil && mac == "" && s.reloadOnMiss && s.reloadAfterMiss() {
+		snap = s.snapshot()
+		instance, device, mac = snap.lookupMACs(macs)
+	}
 
-	// Find instance by MAC address
-	s.mu.RLock()
-	instance := s.findInstanceByMAC(mac)
-	s.mu.RUnlock()
+	// Only MACs shared by several instances matched: there is no single right answer
+	// Only a requester holding one of their tokens learns which instances they are
+	if names := snap.collisions[mac]; len(names) > 0 {
+		logger.Warn("MAC is used by several instances", "instances", names)
+		msg := fmt.Sprintf("MAC %s is used by several instances", mac)
+		if s.verifiedForAny(snap, names, r) {
+			msg += ": " + strings.Join(names, ", ")
+		}
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
 
 	if instance == nil {
-		log.Printf("No instance found for MAC: %s", mac)
+		logger.Warn("No instance found for any MAC")
+		s.metrics.macMiss()
 		http.Error(w, "Instance not found", http.StatusNotFound)
 		return
 	}
 
-	log.Printf("Found instance: %s", instance.Name)
+	logger = logger.With("instance", instance.Name, "mac", mac)
+	logger.Info("Found instance", "device", device)
+
+	// The requester must prove it holds the instance's bootstrap secret
+	// Signatures cover the MACs exactly as sent, before blanks and repeats are dropped
+	sentMACs := r.URL.Query()["mac"]
+	if err := s.verifyToken(instance, sentMACs, r); err != nil {
+		logger.Warn("Rejected config request: bad bootstrap token", "error", err)
+		s.metrics.tokenRejected()
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
 	// Parse all network configs
 	networks := s.parseAllNetworkConfigs(instance)
 
-	// Get primary network (first one) for backwards compatibility
-	var primaryNetwork config.NetworkConfig
-	for _, netCfg := range networks {
-		primaryNetwork = netCfg
-		break
+	// In strict mode the requester must look like the instance it asks for
+	if s.strictSource {
+		if err := checkSource(instance, networks, r); err != nil {
+			logger.Warn("Rejected config request: source does not match instance", "error", err)
+			s.metrics.sourceRejected()
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 	}
-	// If no networks found, default to DHCP
-	if len(networks) == 0 {
-		primaryNetwork = config.NetworkConfig{DHCP: true}
+	s.markFetched(instance.Name)
+
+	// Windows clients say so, for instances whose image does not
+	windows := instance.IsWindows() || r.URL.Query().Get("client") == "windows"
+	response := s.buildConfigResponse(instance, networks, mac, device, windows)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		logger.Error("Error encoding response", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+	body = append(body, '\n')
 
-	// Build response
-	response := config.ConfigResponse{
-		Hostname: instance.Name,
-		Network:  primaryNetwork,
-		Networks: networks,
+	// Sign the exact bytes sent so clients can verify them end to end
+	if s.responseKey != nil {
+		w.Header().Set(signing.SignatureHeader, signing.Sign(s.responseKey, sentMACs, r.Header.Get(signing.NonceHeader), body))
 	}
 
 	// Send JSON response
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+	w.Write(body)
 
-	log.Printf("Sent config for %s: %d network(s), primary: dhcp=%v, address=%s", instance.Name, len(networks), primaryNetwork.DHCP, primaryNetwork.Address)
+	logger.Info("Sent config", "networks", len(networks), "interface", response.Interface,
+		"dhcp", response.Network.DHCP, "addresses", response.Network.AllAddresses())
 }
 
-// findInstanceByMAC finds an instance by checking volatile.*.hwaddr fields
-func (s *Server) findInstanceByMAC(mac string) *config.LXDInstance {
-	for i := range s.instances {
-		inst := &s.instances[i]
+// buildConfigResponse assembles what /config sends for a request from the given MAC and device
+// windows limits the hostname to what a Windows guest accepts
+func (s *Server) buildConfigResponse(instance *config.LXDInstance, networks map[string]config.NetworkConfig, mac, device string, windows bool) config.ConfigResponse {
+	// Primary network is the one for the NIC that owns the requesting MAC
+	primaryName, primaryNetwork := selectPrimaryNetwork(networks, device, mac)
+	if primaryNetwork.MAC == "" && primaryName == device {
+		primaryNetwork.MAC = mac
+	}
 
-		// Check all config keys for MAC addresses (volatile.eth-X.hwaddr pattern)
-		for key, value := range inst.Config {
-			if strings.Contains(key, "hwaddr") {
-				if strings.EqualFold(value, mac) {
-					return inst
-				}
-			}
-		}
+	hostname, fqdn := s.servedHostname(instance, windows)
+
+	return config.ConfigResponse{
+		Hostname:   hostname,
+		FQDN:       fqdn,
+		MatchedMAC: mac,
+		Interface:  primaryName,
+		Network:    primaryNetwork,
+		Networks:   networks,
+		Metadata:   instanceMetadata(instance),
 	}
-	return nil
 }
 
-// parseAllNetworkConfigs parses all ethernet configs from cloud-init.network-config
-func (s *Server) parseAllNetworkConfigs(instance *config.LXDInstance) map[string]config.NetworkConfig {
-	networks := make(map[string]config.NetworkConfig)
-	cloudInitConfig := instance.Config["cloud-init.network-config"]
+// maxRequestMACs bounds how many MACs one /config request may carry
+const maxRequestMACs = 64
 
-	// Check for simple DHCP string
-	if strings.TrimSpace(strings.ToUpper(cloudInitConfig)) == "DHCP" {
-		return networks // Empty map, client will use DHCP
+// requestMACs returns the normalized MACs of a /config request in the order sent,
+// with blanks and repeats dropped
+func requestMACs(r *http.Request) ([]string, error) {
+	values := r.URL.Query()["mac"]
+	if len(values) > maxRequestMACs {
+		return nil, fmt.Errorf("too many 'mac' query parameters (max %d)", maxRequestMACs)
 	}
 
-	// Try to parse as netplan YAML
-	var cloudInit config.CloudInitNetwork
-	if err := yaml.Unmarshal([]byte(cloudInitConfig), &cloudInit); err != nil {
-		log.Printf("Failed to parse cloud-init config for %s: %v", instance.Name, err)
-		return networks
+	var macs []string
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		mac := normalizeMAC(strings.TrimSpace(value))
+		if mac == "" || seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		macs = append(macs, mac)
 	}
 
-	// Parse ALL ethernet devices
-	for ifaceName, eth := range cloudInit.Ethernets {
-		netConfig := config.NetworkConfig{DHCP: eth.DHCP4}
+	if len(macs) == 0 {
+		return nil, fmt.Errorf("missing 'mac' query parameter")
+	}
+	return macs, nil
+}
 
-		// Get first address (for static IP)
-		if len(eth.Addresses) > 0 {
-			netConfig.Address = eth.Addresses[0]
-		}
+// normalizeMAC lowercases a MAC address and uses colon separators
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
 
-		// Get gateway from gateway4
-		if eth.Gateway4 != "" {
-			netConfig.Gateway = eth.Gateway4
-		}
+// selectPrimaryNetwork picks the network for the given device, or else the one
+// matching mac (entries keyed by a logical name with match.macaddress)
+// Falls back to the alphabetically first interface so the choice is stable,
+// and to DHCP when the instance has no network config at all
+func selectPrimaryNetwork(networks map[string]config.NetworkConfig, device, mac string) (string, config.NetworkConfig) {
+	if netCfg, ok := networks[device]; ok {
+		return device, netCfg
+	}
 
-		// Process routes - extract default gateway and collect custom routes
-		for _, route := range eth.Routes {
-			if route.To == "default" || route.To == "0.0.0.0/0" {
-				// Use as gateway if not already set
-				if netConfig.Gateway == "" {
-					netConfig.Gateway = route.Via
-				}
-			} else {
-				// Custom route - add to routes list
-				netConfig.Routes = append(netConfig.Routes, route)
-			}
-		}
+	if len(networks) == 0 {
+		return device, config.NetworkConfig{DHCP: true}
+	}
 
-		// Get DNS servers
-		netConfig.DNS = eth.Nameservers.Addresses
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		networks[ifaceName] = netConfig
+	if mac != "" {
+		for _, name := range names {
+			if strings.EqualFold(networks[name].MAC, mac) {
+				return name, networks[name]
+			}
+		}
 	}
 
-	return networks
+	return names[0], networks[names[0]]
 }
 
 // parseNetworkConfig parses cloud-init.network-config from the instance (legacy, returns first)
 func (s *Server) parseNetworkConfig(instance *config.LXDInstance) config.NetworkConfig {
-	networks := s.parseAllNetworkConfigs(instance)
-	for _, netCfg := range networks {
-		return netCfg
-	}
-	return config.NetworkConfig{DHCP: true}
+	_, netCfg := selectPrimaryNetwork(s.parseAllNetworkConfigs(instance), "", "")
+	return netCfg
 }
 
-// HandleReload handles POST /reload to refresh the instances file
+// HandleReload handles POST /reload to refresh the instances from the source
 func (s *Server) HandleReload(w http.ResponseWriter, r *http.Request) {
 	s.updateActivity()
 
@@ -240,7 +440,7 @@ func (s *Server) HandleReload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Reloaded %d instances\n", len(s.instances))
+	fmt.Fprintf(w, "Reloaded %d instances\n", s.instanceCount())
 }
 
 // HandleStatus handles GET /status to check server status
@@ -250,16 +450,28 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.mu.RLock()
-	instanceCount := len(s.instances)
-	s.mu.RUnlock()
+	instanceCount := s.instanceCount()
 
 	lastActivity := s.GetLastActivity()
+	loadedAt, reloadErr := s.reloadState()
 
 	status := map[string]interface{}{
-		"instances":     instanceCount,
-		"last_activity": lastActivity.Format(time.RFC3339),
+		"instances":      instanceCount,
+		"source":         s.source.String(),
+		"loaded_at":      loadedAt.Format(time.RFC3339),
+		"last_activity":  lastActivity.Format(time.RFC3339),
 		"uptime_seconds": time.Since(lastActivity).Seconds(),
+		"reports":        s.Reports(),
+		"progress":       s.Progress(),
+	}
+	if reloadErr != nil {
+		status["reload_error"] = reloadErr.Error()
+	}
+	if issues := s.Issues(); len(issues) > 0 {
+		status["issues"] = issues
+	}
+	if collisions := s.MACCollisions(); len(collisions) > 0 {
+		status["mac_collisions"] = collisions
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -268,7 +480,12 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 
 // RegisterRoutes registers HTTP routes
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/config", s.HandleConfig)
-	mux.HandleFunc("/reload", s.HandleReload)
-	mux.HandleFunc("/status", s.HandleStatus)
+	mux.HandleFunc("/config", s.instrument("/config", s.allowClients(s.HandleConfig)))
+	mux.HandleFunc("/reload", s.instrument("/reload", s.allowAdmins(s.HandleReload)))
+	mux.HandleFunc("/status", s.instrument("/status", s.allowAdmins(s.HandleStatus)))
+	mux.HandleFunc("/report", s.instrument("/report", s.allowClients(s.HandleReport)))
+	mux.HandleFunc("/metrics", s.instrument("/metrics", s.allowAdmins(s.HandleMetrics)))
+	mux.HandleFunc("/instances", s.instrument("/instances", s.allowAdmins(s.HandleInstances)))
+	mux.HandleFunc("/instances/", s.instrument("/instances", s.allowAdmins(s.HandleInstance)))
+	mux.HandleFunc("/", s.allowAdminSources(s.HandleDashboard))
 }
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"cyber-range-config/internal/config"
+)
+
+func TestSelectPrimaryNetwork(t *testing.T) {
+	networks := map[string]config.NetworkConfig{
+		"eth0": {Addresses: []string{"10.0.0.5/24"}},
+		"lan":  {MAC: "00:16:3e:00:00:02", Addresses: []string{"10.0.1.5/24"}},
+	}
+
+	tests := []struct {
+		name     string
+		networks map[string]config.NetworkConfig
+		device   string
+		mac      string
+		want     string
+	}{
+		{name: "by device", networks: networks, device: "eth0", mac: "00:16:3e:00:00:02", want: "eth0"},
+		{name: "by MAC", networks: networks, device: "eth1", mac: "00:16:3E:00:00:02", want: "lan"},
+		{name: "fallback", networks: networks, device: "eth1", mac: "00:16:3e:00:00:09", want: "eth0"},
+		{name: "no config", device: "eth1", want: "eth1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := selectPrimaryNetwork(tt.networks, tt.device, tt.mac); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"cyber-range-config/internal/config"
+)
+
+// InstanceSource provides the LXD instances the server answers for
+type InstanceSource interface {
+	// Load returns the current list of instances
+	Load() ([]config.LXDInstance, error)
+	// String describes the source for log messages
+	String() string
+}
+
+// FileSource loads instances from a JSON file produced by lxc list --format json
+type FileSource struct {
+	Path string
+}
+
+// NewFileSource creates a source that reads the given instances file
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+// Load reads and parses the instances file
+func (f *FileSource) Load() ([]config.LXDInstance, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instances file: %w", err)
+	}
+
+	var instances []config.LXDInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return nil, fmt.Errorf("failed to parse instances JSON: %w", err)
+	}
+
+	return instances, nil
+}
+
+// String returns the file path
+func (f *FileSource) String() string {
+	return f.Path
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"cyber-range-config/internal/config"
+)
+
+// Issue is a problem found in an instance's config at load time
+type Issue struct {
+	Instance string `json:"instance"`
+	Problem  string `json:"problem"`
+}
+
+// String formats the issue for logs and -check output
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Instance, i.Problem)
+}
+
+// owner identifies the instance and interface or device an address belongs to
+type owner struct {
+	instance string
+	name     string
+}
+
+// String formats the owner as instance (name)
+func (o owner) String() string {
+	return fmt.Sprintf("%s (%s)", o.instance, o.name)
+}
+
+// Validate checks instances for mistakes that would otherwise only show up as a
+// client on the wrong network: invalid YAML, non-CIDR addresses, gateways outside
+// the interface subnet, duplicate IPs and MACs, instances without a hwaddr, and
+// hostnames too long or invalid for the guest OS
+func (s *Server) Validate(instances []config.LXDInstance) []Issue {
+	var issues []Issue
+	addIssue := func(instance, format string, args ...interface{}) {
+		issues = append(issues, Issue{Instance: instance, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	ips := make(map[netip.Addr]owner)
+	macs := make(map[string]owner)
+
+	for i := range instances {
+		instance := &instances[i]
+
+		if !hasHwaddr(instance) {
+			addIssue(instance.Name, "no volatile.*.hwaddr or NIC hwaddr, so no client can fetch this config")
+		}
+
+		devices := instanceMACs(instance)
+		for _, device := range sortedKeys(devices) {
+			mac := devices[device]
+			current := owner{instance.Name, device}
+			if first, ok := macs[mac]; ok {
+				addIssue(instance.Name, "MAC %s of %s is also used by %s", mac, device, first)
+				continue
+			}
+			macs[mac] = current
+		}
+
+		if problem := s.checkHostname(instance); problem != "" {
+			addIssue(instance.Name, "%s", problem)
+		}
+
+		if _, err := decodeNetworkConfig(instance); err != nil {
+			key, _ := networkConfig(instance)
+			addIssue(instance.Name, "invalid %s: %v", key, err)
+			continue
+		}
+
+		networks := s.parseAllNetworkConfigs(instance)
+		for _, name := range config.OrderedNames(networks) {
+			netCfg := networks[name]
+
+			var prefixes []netip.Prefix
+			for _, address := range netCfg.AllAddresses() {
+				prefix, err := netip.ParsePrefix(address)
+				if err != nil {
+					addIssue(instance.Name, "%s: address %q is not in CIDR form (e.g. 10.0.0.5/24)", name, address)
+					continue
+				}
+				prefixes = append(prefixes, prefix)
+
+				ip := prefix.Addr()
+				if first, ok := ips[ip]; ok {
+					addIssue(instance.Name, "%s: IP %s is also assigned to %s", name, ip, first)
+					continue
+				}
+				ips[ip] = owner{instance.Name, name}
+			}
+
+			for _, gateway := range []string{netCfg.Gateway, netCfg.Gateway6} {
+				if problem := checkGateway(gateway, prefixes); problem != "" {
+					addIssue(instance.Name, "%s: %s", name, problem)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Instance < issues[j].Instance })
+	return issues
+}
+
+// checkGateway describes what is wrong with a gateway given the interface's
+// static addresses, or returns "" if nothing is (or there is nothing to check)
+func checkGateway(gateway string, prefixes []netip.Prefix) string {
+	if gateway == "" {
+		return ""
+	}
+
+	ip, err := netip.ParseAddr(gateway)
+	if err != nil {
+		return fmt.Sprintf("gateway %q is not an IP address", gateway)
+	}
+
+	var sameFamily []string
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is4() != ip.Is4() {
+			continue
+		}
+		if prefix.Masked().Contains(ip) {
+			return ""
+		}
+		sameFamily = append(sameFamily, prefix.Masked().String())
+	}
+
+	// DHCP interfaces, or a gateway for a family with no static address
+	if len(sameFamily) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("gateway %s is outside %s", ip, strings.Join(sameFamily, ", "))
+}
+
+// Issues returns the problems found when the instances were last loaded
+func (s *Server) Issues() []Issue {
+	return s.snapshot().issues
+}
+
+// logIssues logs each validation issue as a warning
+func logIssues(issues []Issue) {
+	for _, issue := range issues {
+		slog.Warn("Instance config problem", "instance", issue.Instance, "problem", issue.Problem)
+	}
+}
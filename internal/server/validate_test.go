@@ -0,0 +1,119 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"cyber-range-config/internal/config"
+)
+
+func TestValidate(t *testing.T) {
+	// instance builds an instance with one NIC and an optional network config
+	instance := func(name, mac, network string) config.LXDInstance {
+		cfg := map[string]string{}
+		if mac != "" {
+			cfg["volatile.eth0.hwaddr"] = mac
+		}
+		if network != "" {
+			cfg["cloud-init.network-config"] = network
+		}
+		return config.LXDInstance{Name: name, Config: cfg}
+	}
+	static := func(address, gateway string) string {
+		network := "version: 2\nethernets:\n  eth0:\n    addresses: [" + address + "]\n"
+		if gateway != "" {
+			network += "    gateway4: " + gateway + "\n"
+		}
+		return network
+	}
+
+	tests := []struct {
+		name      string
+		instances []config.LXDInstance
+		want      []string // Expected issues as "instance: problem" substrings, in order
+	}{
+		{
+			name: "clean",
+			instances: []config.LXDInstance{
+				instance("web01", "00:16:3e:00:00:01", static("10.0.0.5/24", "10.0.0.1")),
+				instance("web02", "00:16:3e:00:00:02", "version: 2\nethernets:\n  eth0:\n    dhcp4: true\n"),
+			},
+		},
+		{
+			name:      "no hwaddr",
+			instances: []config.LXDInstance{instance("web01", "", "")},
+			want:      []string{"web01: no volatile.*.hwaddr"},
+		},
+		{
+			name: "duplicate MAC",
+			instances: []config.LXDInstance{
+				instance("web01", "00:16:3e:00:00:01", ""),
+				instance("web02", "00:16:3E:00:00:01", ""),
+			},
+			want: []string{"web02: MAC 00:16:3e:00:00:01 of eth0 is also used by web01 (eth0)"},
+		},
+		{
+			name:      "invalid YAML",
+			instances: []config.LXDInstance{instance("web01", "00:16:3e:00:00:01", "version: 2\nethernets: [")},
+			want:      []string{"web01: invalid cloud-init.network-config"},
+		},
+		{
+			name:      "address not CIDR",
+			instances: []config.LXDInstance{instance("web01", "00:16:3e:00:00:01", static("10.0.0.5", ""))},
+			want:      []string{`web01: eth0: address "10.0.0.5" is not in CIDR form`},
+		},
+		{
+			name: "duplicate IP",
+			instances: []config.LXDInstance{
+				instance("web01", "00:16:3e:00:00:01", static("10.0.0.5/24", "")),
+				instance("web02", "00:16:3e:00:00:02", static("10.0.0.5/24", "")),
+			},
+			want: []string{"web02: eth0: IP 10.0.0.5 is also assigned to web01 (eth0)"},
+		},
+		{
+			name:      "gateway outside subnet",
+			instances: []config.LXDInstance{instance("web01", "00:16:3e:00:00:01", static("10.0.0.5/24", "10.0.1.1"))},
+			want:      []string{"web01: eth0: gateway 10.0.1.1 is outside 10.0.0.0/24"},
+		},
+		{
+			name:      "gateway not an IP",
+			instances: []config.LXDInstance{instance("web01", "00:16:3e:00:00:01", static("10.0.0.5/24", "router"))},
+			want:      []string{`web01: eth0: gateway "router" is not an IP address`},
+		},
+		{
+			name:      "invalid hostname",
+			instances: []config.LXDInstance{instance("web_01", "00:16:3e:00:00:01", "")},
+			want:      []string{`web_01: hostname "web_01" may only contain letters, digits and inner hyphens; serving "web-01" instead`},
+		},
+		{
+			name: "Windows hostname too long",
+			instances: []config.LXDInstance{{
+				Name:   "team1-windows-server",
+				Config: map[string]string{"volatile.eth0.hwaddr": "00:16:3e:00:00:01", "image.os": "Windows"},
+			}},
+			want: []string{`team1-windows-server: hostname "team1-windows-server" is 20 characters, over the limit of 15; serving "team1-windows-s" instead`},
+		},
+		{
+			name: "issues sorted by instance",
+			instances: []config.LXDInstance{
+				instance("web02", "", ""),
+				instance("web01", "", ""),
+			},
+			want: []string{"web01: no volatile", "web02: no volatile"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := (&Server{}).Validate(tt.instances)
+			if len(issues) != len(tt.want) {
+				t.Fatalf("got issues %v, want %d", issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(issues[i].String(), want) {
+					t.Errorf("issue %d: got %q, want %q", i, issues[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Headers carrying the client's nonce and the server's signature of a /config response
+const (
+	NonceHeader     = "X-CR-Nonce"
+	SignatureHeader = "X-CR-Response-Signature"
+)
+
+// Sign returns the base64 Ed25519 signature of a response body for the requested MACs and nonce
+// Binding the MACs and nonce stops a proxy from replaying another instance's (or an old) response
+func Sign(key ed25519.PrivateKey, macs []string, nonce string, body []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, message(macs, nonce, body)))
+}
+
+// Verify checks a response signature made by Sign
+func Verify(key ed25519.PublicKey, macs []string, nonce string, body []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("response is not signed")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(key, message(macs, nonce, body), sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
+
+// message builds the signed bytes: comma-separated MACs, nonce and body separated by newlines
+func message(macs []string, nonce string, body []byte) []byte {
+	mac := strings.ToLower(strings.ReplaceAll(strings.Join(macs, ","), "-", ":"))
+	msg := make([]byte, 0, len(mac)+len(nonce)+len(body)+2)
+	msg = append(msg, mac...)
+	msg = append(msg, '\n')
+	msg = append(msg, nonce...)
+	msg = append(msg, '\n')
+	return append(msg, body...)
+}
+
+// LoadPrivateKey reads a PKCS#8 PEM Ed25519 private key (openssl genpkey -algorithm ed25519)
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key found in %s", file)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", file)
+	}
+
+	return key, nil
+}
+
+// EncodePublicKey renders a public key in the base64 form clients are built with
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is %d bytes, want %d", len(data), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(data), nil
+}
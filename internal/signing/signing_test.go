@@ -0,0 +1,93 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	macs := []string{"00:16:3e:00:00:01", "00:16:3e:00:00:02"}
+	body := []byte(`{"hostname":"web01"}` + "\n")
+	signature := Sign(private, macs, "nonce-1", body)
+
+	tests := []struct {
+		name      string
+		key       ed25519.PublicKey
+		macs      []string
+		nonce     string
+		body      []byte
+		signature string
+		wantErr   string
+	}{
+		{name: "valid", key: public, macs: macs, nonce: "nonce-1", body: body, signature: signature},
+		{name: "MACs normalized", key: public, macs: []string{"00-16-3E-00-00-01", "00:16:3E:00:00:02"}, nonce: "nonce-1", body: body, signature: signature},
+		{name: "unsigned", key: public, macs: macs, nonce: "nonce-1", body: body, wantErr: "not signed"},
+		{name: "bad encoding", key: public, macs: macs, nonce: "nonce-1", body: body, signature: "not base64!", wantErr: "invalid signature encoding"},
+		{name: "body tampered", key: public, macs: macs, nonce: "nonce-1", body: []byte(`{"hostname":"evil"}` + "\n"), signature: signature, wantErr: "signature mismatch"},
+		{name: "nonce replayed", key: public, macs: macs, nonce: "nonce-2", body: body, signature: signature, wantErr: "signature mismatch"},
+		{name: "other instance's MACs", key: public, macs: []string{"00:16:3e:00:00:03"}, nonce: "nonce-1", body: body, signature: signature, wantErr: "signature mismatch"},
+		{name: "wrong key", key: otherPublic, macs: macs, nonce: "nonce-1", body: body, signature: signature, wantErr: "signature mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.key, tt.macs, tt.nonce, tt.body, tt.signature)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "round trip", value: EncodePublicKey(public)},
+		{name: "surrounding whitespace", value: " " + EncodePublicKey(public) + "\n"},
+		{name: "bad encoding", value: "not base64!", wantErr: "invalid public key encoding"},
+		{name: "wrong size", value: EncodePublicKey(public[:16]), wantErr: "public key is 16 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePublicKey(tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !key.Equal(public) {
+					t.Fatalf("got key %x, want %x", key, public)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
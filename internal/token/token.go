@@ -0,0 +1,102 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigKey is the LXD instance config key holding the per-instance bootstrap secret
+const ConfigKey = "user.cyber-range.token"
+
+// Request headers carrying the signature of a /config or /report call
+const (
+	TimestampHeader = "X-CR-Timestamp"
+	SignatureHeader = "X-CR-Signature"
+)
+
+// SMBIOSPrefix marks the bootstrap secret among a VM's SMBIOS OEM strings (type 11),
+// which is how it reaches Windows guests: they have no /dev/lxd/sock to read it from
+const SMBIOSPrefix = "cyber-range.token="
+
+// MaxSkew is how far a request timestamp may be from the server clock
+const MaxSkew = 5 * time.Minute
+
+// Sign returns the hex HMAC-SHA256 of the requested MACs and Unix timestamp under the instance secret
+func Sign(secret string, macs []string, timestamp int64) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s\n%d", joinMACs(macs), timestamp)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SignBody is Sign for requests with a body, such as /report, and also covers the body
+func SignBody(secret string, macs []string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s\n%d\n", joinMACs(macs), timestamp)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks a signature and that its timestamp is within MaxSkew of now
+func Verify(secret string, macs []string, timestamp, signature string, now time.Time) error {
+	ts, err := checkTimestamp(timestamp, signature, now)
+	if err != nil {
+		return err
+	}
+	return checkSignature(Sign(secret, macs, ts), signature)
+}
+
+// VerifyBody checks a SignBody signature and that its timestamp is within MaxSkew of now
+func VerifyBody(secret string, macs []string, timestamp, signature string, body []byte, now time.Time) error {
+	ts, err := checkTimestamp(timestamp, signature, now)
+	if err != nil {
+		return err
+	}
+	return checkSignature(SignBody(secret, macs, ts, body), signature)
+}
+
+// checkTimestamp parses a request timestamp and checks it is within MaxSkew of now
+func checkTimestamp(timestamp, signature string, now time.Time) (int64, error) {
+	if timestamp == "" || signature == "" {
+		return 0, fmt.Errorf("request is not signed")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > MaxSkew || skew < -MaxSkew {
+		return 0, fmt.Errorf("timestamp off by %v", skew.Round(time.Second))
+	}
+
+	return ts, nil
+}
+
+// checkSignature compares a hex signature to the expected one in constant time
+func checkSignature(want, signature string) error {
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(signature))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// joinMACs normalizes the MACs and joins them with commas, in request order
+// A single MAC signs exactly as it did before clients sent all of theirs
+func joinMACs(macs []string) string {
+	normalized := make([]string, len(macs))
+	for i, mac := range macs {
+		normalized[i] = normalizeMAC(mac)
+	}
+	return strings.Join(normalized, ",")
+}
+
+// normalizeMAC lowercases a MAC address and uses colon separators
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
@@ -0,0 +1,119 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	macs := []string{"00:16:3e:00:00:01", "00:16:3e:00:00:02"}
+	signed := func(ts time.Time) (string, string) {
+		return strconv.FormatInt(ts.Unix(), 10), Sign("s3cret", macs, ts.Unix())
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		macs      []string
+		timestamp string
+		signature string
+		wantErr   string
+	}{
+		{name: "valid", secret: "s3cret", macs: macs},
+		{name: "MACs normalized", secret: "s3cret", macs: []string{"00-16-3E-00-00-01", "00:16:3E:00:00:02"}},
+		{name: "uppercase signature", secret: "s3cret", macs: macs, signature: "upper"},
+		{name: "unsigned", secret: "s3cret", macs: macs, timestamp: "-", signature: "-", wantErr: "not signed"},
+		{name: "wrong secret", secret: "other", macs: macs, wantErr: "signature mismatch"},
+		{name: "MACs reordered", secret: "s3cret", macs: []string{macs[1], macs[0]}, wantErr: "signature mismatch"},
+		{name: "MAC dropped", secret: "s3cret", macs: macs[:1], wantErr: "signature mismatch"},
+		{name: "bad timestamp", secret: "s3cret", macs: macs, timestamp: "yesterday", wantErr: "invalid timestamp"},
+		{name: "timestamp changed", secret: "s3cret", macs: macs, timestamp: strconv.FormatInt(now.Unix()+1, 10), wantErr: "signature mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp, signature := signed(now)
+			switch {
+			case tt.timestamp == "-":
+				timestamp, signature = "", ""
+			case tt.timestamp != "":
+				timestamp = tt.timestamp
+			}
+			if tt.signature == "upper" {
+				signature = strings.ToUpper(signature)
+			}
+
+			err := Verify(tt.secret, tt.macs, timestamp, signature, now)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifySkew(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	macs := []string{"00:16:3e:00:00:01"}
+
+	tests := []struct {
+		name    string
+		offset  time.Duration
+		wantErr string
+	}{
+		{name: "exact", offset: 0},
+		{name: "behind within skew", offset: -MaxSkew},
+		{name: "ahead within skew", offset: MaxSkew},
+		{name: "too old", offset: -MaxSkew - time.Second, wantErr: "timestamp off by 5m1s"},
+		{name: "too new", offset: MaxSkew + time.Second, wantErr: "timestamp off by -5m1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := now.Add(tt.offset).Unix()
+			err := Verify("s3cret", macs, strconv.FormatInt(ts, 10), Sign("s3cret", macs, ts), now)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifyBody(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	macs := []string{"00:16:3e:00:00:01"}
+	body := []byte(`{"mac":"00:16:3e:00:00:01","success":true}`)
+	ts := now.Unix()
+	timestamp := strconv.FormatInt(ts, 10)
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		wantErr   string
+	}{
+		{name: "valid", signature: SignBody("s3cret", macs, ts, body), body: body},
+		{name: "body changed", signature: SignBody("s3cret", macs, ts, body), body: []byte(`{"success":false}`), wantErr: "signature mismatch"},
+		{name: "config signature reused", signature: Sign("s3cret", macs, ts), body: nil, wantErr: "signature mismatch"},
+		{name: "unsigned", body: body, wantErr: "not signed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyBody("s3cret", macs, timestamp, tt.signature, tt.body, now)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+// checkErr fails the test unless err matches wantErr ("" for no error)
+func checkErr(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("got error %v, want %q", err, wantErr)
+	}
+}
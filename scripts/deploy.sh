@@ -9,6 +9,7 @@ PROJECT_NAME="${PROJECT_NAME:-dcig}"
 SERVER_PORT="${SERVER_PORT:-8080}"
 INSTANCES_FILE="${INSTANCES_FILE:-instances.json}"
 IDLE_TIMEOUT="${IDLE_TIMEOUT:-5m}"
+DEADLINE="${DEADLINE:-}"  # e.g. 2h: stay up until every instance checked in (replaces idle timeout)
 SUBNETS_FILE="${SUBNETS_FILE:-subnets.json}"
 
 # Colors for output
@@ -136,6 +137,15 @@ export_instances() {
     log_info "Exported $instance_count instances"
 }
 
+# Describe when the server shuts itself down
+shutdown_description() {
+    if [ -n "$DEADLINE" ]; then
+        echo "Server will auto-shutdown once every instance has checked in, or after $DEADLINE"
+    else
+        echo "Server will auto-shutdown after $IDLE_TIMEOUT of inactivity"
+    fi
+}
+
 # Start the config server
 start_server() {
     # Get listen address from config.yaml
@@ -145,15 +155,19 @@ start_server() {
     # Kill any existing server
     pgrep -x server | xargs -r kill 2>/dev/null || true
     
-    # Start server in background with idle timeout
-    nohup ./server -listen "$LISTEN_ADDR" -instances "$INSTANCES_FILE" -idle-timeout "$IDLE_TIMEOUT" > server.log 2>&1 &
+    # Start server in background with idle timeout (or completion deadline)
+    DEADLINE_ARGS=()
+    if [ -n "$DEADLINE" ]; then
+        DEADLINE_ARGS=(-deadline "$DEADLINE")
+    fi
+    nohup ./server -listen "$LISTEN_ADDR" -instances "$INSTANCES_FILE" -idle-timeout "$IDLE_TIMEOUT" "${DEADLINE_ARGS[@]}" > server.log 2>&1 &
     SERVER_PID=$!
     
     sleep 2
     
     if ps -p $SERVER_PID > /dev/null; then
         log_info "Server started (PID: $SERVER_PID)"
-        log_info "Server will auto-shutdown after $IDLE_TIMEOUT of inactivity"
+        log_info "$(shutdown_description)"
         log_info "Server log: server.log"
     else
         log_error "Server failed to start. Check server.log"
@@ -193,15 +207,15 @@ main() {
     log_info "Deployment complete!"
     echo ""
     echo "Server running at: http://${LISTEN_ADDR}"
-    echo "Idle timeout: $IDLE_TIMEOUT (server will auto-shutdown after no requests)"
+    echo "Shutdown: $(shutdown_description)"
     echo ""
     echo "Endpoints:"
     echo "  GET  /config?mac=XX:XX:XX:XX:XX:XX  - Get VM config"
     echo "  POST /reload                         - Reload instances.json"
-    echo "  GET  /status                         - Check server status"
+    echo "  GET  /status                         - Check server status and check-ins"
+    echo "  POST /report                         - Client check-in"
     echo ""
     echo "Windows VMs will automatically configure themselves on boot."
-    echo "Server will shutdown automatically after $IDLE_TIMEOUT of inactivity."
 }
 
 # Run main
@@ -3,7 +3,10 @@
 
 param(
     [Parameter(Mandatory=$true)]
-    [string]$ServerURL
+    [string]$ServerURL,
+
+    # Extra client flags, e.g. "-ca C:\ProgramData\cyber-range\ca.pem"
+    [string]$ClientArgs = ""
 )
 
 $TaskName = "CyberRangeConfig"
@@ -37,7 +40,7 @@ if ($existingTask) {
 }
 
 # Create task
-$action = New-ScheduledTaskAction -Execute $ClientPath -Argument "-server $ServerURL"
+$action = New-ScheduledTaskAction -Execute $ClientPath -Argument "-server $ServerURL $ClientArgs"
 $trigger = New-ScheduledTaskTrigger -AtStartup
 $principal = New-ScheduledTaskPrincipal -UserId "SYSTEM" -LogonType ServiceAccount -RunLevel Highest
 $settings = New-ScheduledTaskSettingsSet -AllowStartIfOnBatteries -DontStopIfGoingOnBatteries -StartWhenAvailable